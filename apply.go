@@ -0,0 +1,87 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"context"
+	"fmt"
+)
+
+// Apply calls the form with the given arguments and returns the result.
+// The arguments are not evaluated. Therefore, a special form cannot be
+// applied, since it expects unevaluated expressions. The arity of the form
+// is checked, and a tail call returned by the form is evaluated. It allows
+// builtins, like a MAP function, to call forms given as arguments.
+//
+// If the call fails, the error is an EvalError, that records the call.
+func Apply(env Environment, form Form, args []Value) (Value, error) {
+	if st := stateOf(env); st != nil && st.ctx == nil {
+		defer st.start(context.Background())()
+	}
+	res, err := applyForm(env, form, args)
+	if err == nil {
+		res, err = resolveTailCall(res, err)
+	}
+	if err != nil {
+		return nil, addFrame(env, err, NewPair(form, NewPairFromSlice(args)), form, args)
+	}
+	return res, nil
+}
+
+// applyForm calls a non-special form. The result may be a tail call.
+func applyForm(env Environment, form Form, args []Value) (Value, error) {
+	if form == nil {
+		return nil, fmt.Errorf("no form to apply")
+	}
+	if form.IsSpecial() {
+		return nil, fmt.Errorf("special form %v cannot be applied", form)
+	}
+	return callForm(env, nil, form, args)
+}
+
+// (FUNCALL form arg...) calls form with the arguments.
+func funcallFn(env Environment, args []Value) (Value, error) {
+	form, err := GetForm(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return applyForm(env, form, args[1:])
+}
+
+// (APPLY form arg... seq) calls form with the arguments, followed by the
+// elements of the sequence.
+func applyFn(env Environment, args []Value) (Value, error) {
+	form, err := GetForm(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	last := len(args) - 1
+	seq, err := GetSequence(args, last)
+	if err != nil {
+		return nil, err
+	}
+	var vals []Value
+	if p, isPair := seq.(*Pair); isPair {
+		var tail Value
+		if vals, tail = p.getElems(); tail != nil {
+			return nil, fmt.Errorf("%v is not a proper list", p)
+		}
+	} else {
+		vals = seq.GetSlice()
+	}
+	if err = Allocate(env, last-1+len(vals)); err != nil {
+		return nil, err
+	}
+	callArgs := make([]Value, 0, last-1+len(vals))
+	callArgs = append(callArgs, args[1:last]...)
+	callArgs = append(callArgs, vals...)
+	return applyForm(env, form, callArgs)
+}
@@ -0,0 +1,136 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestApply(t *testing.T) {
+	engine := newTestEngine()
+	expr, err := sxpf.ParseString(engine, "(LAMBDA (x y) (IF x y (CAT y y)))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closure, err := engine.Eval(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []sxpf.Value{sxpf.Nil(), sxpf.NewString("a")}
+	if val, err2 := sxpf.Apply(engine, closure.(sxpf.Form), args); err2 != nil {
+		t.Error(err2)
+	} else if got := val.String(); got != `"\"a\"\"a\""` {
+		t.Errorf("unexpected result %v", got)
+	}
+
+	testcases := []struct {
+		name string
+		args []sxpf.Value
+		msg  string
+	}{
+		{"LAMBDA", []sxpf.Value{sxpf.Nil()}, "special form #LAMBDA cannot be applied"},
+		{"CAT", nil, ""},
+	}
+	for i, tc := range testcases {
+		form, err2 := engine.LookupForm(engine.MakeSymbol(tc.name))
+		if err2 != nil {
+			t.Fatal(err2)
+		}
+		_, err2 = sxpf.Apply(engine, form, tc.args)
+		if got := errorMessage(err2); got != tc.msg {
+			t.Errorf("%d: applying %v should result in %q, but got %q", i, tc.name, tc.msg, got)
+		}
+	}
+	if _, err = sxpf.Apply(engine, closure.(sxpf.Form), nil); err == nil {
+		t.Error("arity of closure must be checked")
+	}
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestApplyForms(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(FUNCALL CAT (QUOTE a) (QUOTE b))", `"AB"`},
+		{"(FUNCALL (LAMBDA () (QUOTE a)))", "A"},
+		{"(APPLY CAT (QUOTE (a b)))", `"AB"`},
+		{"(APPLY CAT (QUOTE a) (QUOTE [b c]))", `"ABC"`},
+		{"(BEGIN (DEFINE (twice f x) (FUNCALL f (FUNCALL f x))) (twice (LAMBDA (y) (CAT y y)) (QUOTE a)))", `"\"AA\"\"AA\""`},
+		{"(BEGIN (DEFINE (loop n) (IF n (APPLY loop (QUOTE (()))) (QUOTE done))) (loop 1))", "DONE"},
+		{"(FUNCALL #CAT (QUOTE a))", `"A"`},
+		{"#CAT", "#CAT"},
+		{"(FUNCALL IF #t 1)", "special form #IF cannot be applied"},
+		{"(FUNCALL 1)", "1 / 0 is not a form"},
+		{"(APPLY CAT 1)", "1 / 1 is not a sequence"},
+		{"(APPLY CAT (QUOTE (a b . c)))", "(A B . C) is not a proper list"},
+		{"(FUNCALL (LAMBDA (x) x))", "not enough arguments (0) for form #<closure> (1)"},
+	}
+	for i, tc := range testcases {
+		engine := newTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		var got string
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = val.String()
+		}
+		if got != tc.exp {
+			t.Errorf("%d: %v should result in %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+	}
+}
+
+func TestParseBuiltinRef(t *testing.T) {
+	engine := newTestEngine()
+	val, err := sxpf.ParseString(engine, "(#cat #QUOTE #unknown)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := val.(*sxpf.Pair).GetSlice()
+	cat, _ := engine.LookupForm(engine.MakeSymbol("CAT"))
+	if elems[0] != cat {
+		t.Errorf("#cat should be read as builtin %v, but got %T/%v", cat, elems[0], elems[0])
+	}
+	if b, ok := elems[1].(*sxpf.Builtin); !ok || b.Name() != "QUOTE" {
+		t.Errorf("#QUOTE should be read as builtin, but got %T/%v", elems[1], elems[1])
+	}
+	if _, ok := elems[2].(*sxpf.Symbol); !ok {
+		t.Errorf("#unknown should be read as symbol, but got %T/%v", elems[2], elems[2])
+	}
+	if got := val.String(); got != "(#CAT #QUOTE #UNKNOWN)" {
+		t.Errorf("unexpected printed value %v", got)
+	}
+}
+
+func TestParseBuiltinRefNoSymbol(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	engine := sxpf.NewEngine(smk)
+	if _, err := sxpf.ParseString(engine, "#missing"); err != nil {
+		t.Fatal(err)
+	}
+	if !sxpf.NewTransientSymbols(smk, 0).MakeSymbol("missing").IsTransient() {
+		t.Error("#missing must not create the symbol MISSING")
+	}
+}
@@ -0,0 +1,132 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import "fmt"
+
+// BasicEnvironment is a configurable Environment, that is also a
+// SymbolMaker. It combines a SymbolMaker with a SymbolMap, and evaluates
+// values according to an EvalStrategy.
+//
+// In contrast to an Engine, it has no special forms, no macros, and no
+// limits. It is intended for applications that evaluate s-expressions with
+// their own builtins.
+type BasicEnvironment struct {
+	smk      SymbolMaker
+	symMap   *SymbolMap
+	strategy EvalStrategy
+}
+
+// EvalStrategy defines how a BasicEnvironment evaluates strings, symbols,
+// pair lists, and vectors. If a function is nil, the default evaluation is
+// used:
+//
+//   - a string evaluates to itself,
+//   - a symbol evaluates to the value bound in the symbol map,
+//   - a non-empty list or vector is evaluated as a form call (see
+//     EvaluateCall), the empty list and the empty vector evaluate to
+//     themselves.
+type EvalStrategy struct {
+	String func(*BasicEnvironment, *String) (Value, error)
+	Symbol func(*BasicEnvironment, *Symbol) (Value, error)
+	List   func(*BasicEnvironment, *Pair) (Value, error)
+	Vector func(*BasicEnvironment, *Vector) (Value, error)
+}
+
+// NewBasicEnvironment creates a new environment with an empty symbol map. If
+// the symbol maker is nil, a new one is created by NewTrivialSymbolMaker.
+func NewBasicEnvironment(smk SymbolMaker, strategy EvalStrategy) *BasicEnvironment {
+	if smk == nil {
+		smk = NewTrivialSymbolMaker()
+	}
+	return &BasicEnvironment{smk: smk, symMap: NewSymbolMap(nil), strategy: strategy}
+}
+
+// NewChild creates a new environment, whose symbol map has the symbol map
+// of this environment as its parent. Symbol maker and strategy are shared.
+func (be *BasicEnvironment) NewChild() *BasicEnvironment {
+	return &BasicEnvironment{smk: be.smk, symMap: NewSymbolMap(be.symMap), strategy: be.strategy}
+}
+
+// MakeSymbol creates a symbol by using the symbol maker of the environment.
+func (be *BasicEnvironment) MakeSymbol(s string) *Symbol { return be.smk.MakeSymbol(s) }
+
+// SymbolMap returns the symbol map of the environment.
+func (be *BasicEnvironment) SymbolMap() *SymbolMap { return be.symMap }
+
+// Define binds the symbol to the given value in the environment. A transient
+// symbol is promoted, unless the environment was created by NewChild.
+func (be *BasicEnvironment) Define(sym *Symbol, val Value) {
+	if be.symMap.Parent() == nil {
+		sym.Promote()
+	}
+	be.symMap.Set(sym, val)
+}
+
+// BindBuiltin binds the builtin to the symbol of its name.
+func (be *BasicEnvironment) BindBuiltin(b *Builtin) { be.Define(be.MakeSymbol(b.Name()), b) }
+
+// Eval evaluates the given value in the environment.
+func (be *BasicEnvironment) Eval(val Value) (Value, error) { return Evaluate(be, val) }
+
+// LookupForm returns the form bound to the given symbol.
+func (be *BasicEnvironment) LookupForm(sym *Symbol) (Form, error) {
+	return be.symMap.LookupForm(sym)
+}
+
+// EvaluateString evaluates the string according to the strategy.
+func (be *BasicEnvironment) EvaluateString(str *String) (Value, error) {
+	if fn := be.strategy.String; fn != nil {
+		return fn(be, str)
+	}
+	return str, nil
+}
+
+// EvaluateSymbol evaluates the symbol according to the strategy.
+func (be *BasicEnvironment) EvaluateSymbol(sym *Symbol) (Value, error) {
+	if fn := be.strategy.Symbol; fn != nil {
+		return fn(be, sym)
+	}
+	if val, found := be.symMap.Lookup(sym); found {
+		return val, nil
+	}
+	return nil, ErrNotBound(sym)
+}
+
+// EvaluateList evaluates the pair list according to the strategy.
+func (be *BasicEnvironment) EvaluateList(p *Pair) (Value, error) {
+	if fn := be.strategy.List; fn != nil {
+		return fn(be, p)
+	}
+	if p == nil {
+		return p, nil
+	}
+	return be.evalAsCall(p, p.GetSlice())
+}
+
+// EvaluateVector evaluates the vector according to the strategy.
+func (be *BasicEnvironment) EvaluateVector(v *Vector) (Value, error) {
+	if fn := be.strategy.Vector; fn != nil {
+		return fn(be, v)
+	}
+	vals := v.GetSlice()
+	if len(vals) == 0 {
+		return v, nil
+	}
+	return be.evalAsCall(v, vals)
+}
+
+func (be *BasicEnvironment) evalAsCall(val Value, vals []Value) (Value, error) {
+	if res, err, done := evaluateCall(be, val, vals); done {
+		return res, err
+	}
+	return nil, fmt.Errorf("%v is not a form call", val)
+}
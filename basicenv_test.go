@@ -0,0 +1,93 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestBasicEnvironment(t *testing.T) {
+	env := sxpf.NewBasicEnvironment(nil, sxpf.EvalStrategy{})
+	env.BindBuiltin(listForm)
+	env.Define(env.MakeSymbol("x"), sxpf.NewInteger(1))
+	child := env.NewChild()
+	child.Define(child.MakeSymbol("y"), sxpf.NewInteger(2))
+
+	testcases := []struct {
+		env *sxpf.BasicEnvironment
+		src string
+		exp string
+	}{
+		{env, `"a"`, `"a"`},
+		{env, "x", "1"},
+		{env, "()", "()"},
+		{env, "[]", "[]"},
+		{env, "(LIST x [LIST x])", "(1 (1))"},
+		{child, "(LIST x y)", "(1 2)"},
+		{env, "y", `symbol "Y" not bound`},
+		{env, "(1 2)", "(1 2) is not a form call"},
+	}
+	for i, tc := range testcases {
+		expr, err := sxpf.ParseString(tc.env, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := tc.env.Eval(expr)
+		var got string
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = val.String()
+		}
+		if got != tc.exp {
+			t.Errorf("%d: %v should result in %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+	}
+}
+
+func TestBasicEnvironmentTransient(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	env := sxpf.NewBasicEnvironment(smk, sxpf.EvalStrategy{})
+	child := env.NewChild()
+	child.Define(sxpf.NewTransientSymbols(smk, 0).MakeSymbol("local"), sxpf.NewInteger(1))
+	if !sxpf.NewTransientSymbols(smk, 0).MakeSymbol("local").IsTransient() {
+		t.Error("symbol defined in child environment must not be promoted")
+	}
+	env.Define(sxpf.NewTransientSymbols(smk, 0).MakeSymbol("global"), sxpf.NewInteger(2))
+	if sxpf.NewTransientSymbols(smk, 0).MakeSymbol("global").IsTransient() {
+		t.Error("symbol defined in root environment must be promoted")
+	}
+}
+
+func TestBasicEnvironmentStrategy(t *testing.T) {
+	env := sxpf.NewBasicEnvironment(sxpf.NewSymbolMaker(sxpf.CasePreserving), sxpf.EvalStrategy{
+		String: func(_ *sxpf.BasicEnvironment, str *sxpf.String) (sxpf.Value, error) {
+			return sxpf.NewString(strings.ToUpper(str.GetValue())), nil
+		},
+		Symbol: func(_ *sxpf.BasicEnvironment, sym *sxpf.Symbol) (sxpf.Value, error) { return sym, nil },
+	})
+	env.BindBuiltin(listForm)
+	expr, err := sxpf.ParseString(env, `(list Abc "abc")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := env.Eval(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := val.String(); got != `(Abc "ABC")` {
+		t.Errorf("expected (Abc \"ABC\"), but got %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+// Boolean is one of the two truth values. There are exactly two Boolean
+// values, True() and False(). They are written as "#t" and "#f".
+type Boolean struct {
+	val bool
+}
+
+var (
+	trueValue  = &Boolean{true}
+	falseValue = &Boolean{false}
+)
+
+// True returns the true value.
+func True() *Boolean { return trueValue }
+
+// False returns the false value.
+func False() *Boolean { return falseValue }
+
+// MakeBoolean returns the truth value of the given Go boolean.
+func MakeBoolean(b bool) *Boolean {
+	if b {
+		return trueValue
+	}
+	return falseValue
+}
+
+// IsTrue returns the truth value of any value. Only the false value, the
+// empty list, and nil are false; all other values, including the empty
+// vector, the empty string, and the number 0 are true.
+//
+// The evaluator uses this predicate, e.g. in IF and COND.
+func IsTrue(val Value) bool {
+	switch v := val.(type) {
+	case *Boolean:
+		return v != nil && v.val
+	case *Pair:
+		return v != nil
+	}
+	return val != nil
+}
+
+// GetValue returns the boolean value.
+func (b *Boolean) GetValue() bool { return b.val }
+
+// Equal retruns true if the other value is equal to this one.
+func (b *Boolean) Equal(other Value) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+	if o, ok := other.(*Boolean); ok {
+		return b.val == o.val
+	}
+	return false
+}
+
+func (b *Boolean) String() string {
+	if b.val {
+		return "#t"
+	}
+	return "#f"
+}
+func (b *Boolean) Value() string { return b.String() }
+
+// parseBoolean returns the truth value of the given symbol name, if it is a
+// written boolean value.
+func parseBoolean(s string) (*Boolean, bool) {
+	switch s {
+	case "#t", "#T", "#true", "#TRUE":
+		return trueValue, true
+	case "#f", "#F", "#false", "#FALSE":
+		return falseValue, true
+	}
+	return nil, false
+}
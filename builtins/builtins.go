@@ -0,0 +1,48 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+// Package builtins provides a standard library of builtins for sxpf engines.
+//
+// The builtins are grouped by topic. All builtins check their arguments and
+// return an error, if an argument has the wrong type. Predicates return one
+// of the boolean values True() and False().
+package builtins
+
+import "github.com/t73fde/sxpf"
+
+// Binder binds builtins, e.g. an Engine or a BasicEnvironment.
+type Binder interface {
+	BindBuiltin(*sxpf.Builtin)
+}
+
+// Register binds the builtins of the given groups, e.g. in the current scope
+// of an engine. If no group is given, all builtins are bound.
+func Register(e Binder, groups ...[]*sxpf.Builtin) {
+	if len(groups) == 0 {
+		groups = [][]*sxpf.Builtin{Numbers, Comparisons, Logic, Strings, Lists, Types, Symbols}
+	}
+	for _, group := range groups {
+		for _, b := range group {
+			e.BindBuiltin(b)
+		}
+	}
+}
+
+// predicate creates a pure builtin with one argument, that returns a truth
+// value.
+func predicate(name string, pred func(sxpf.Value) bool) *sxpf.Builtin {
+	return sxpf.NewBuiltin(
+		name,
+		false, 1, 1,
+		func(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+			return sxpf.MakeBoolean(pred(args[0])), nil
+		},
+	).WithPure()
+}
@@ -0,0 +1,274 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package builtins_test
+
+import (
+	"testing"
+
+	"github.com/t73fde/sxpf"
+	"github.com/t73fde/sxpf/builtins"
+)
+
+func newTestEngine() *sxpf.Engine {
+	engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+	builtins.Register(engine)
+	return engine
+}
+
+// runCompiled parses the source, compiles it, and runs the code.
+func runCompiled(engine *sxpf.Engine, src string) (sxpf.Value, error) {
+	expr, err := sxpf.ParseString(engine, src)
+	if err != nil {
+		return nil, err
+	}
+	code, err := engine.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return code.Run()
+}
+
+func TestBuiltins(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(+)", "0"},
+		{"(+ 1 2 3)", "6"},
+		{"(- 5)", "-5"},
+		{"(- 10 3 2)", "5"},
+		{"(* 2 3 4)", "24"},
+		{"(/ 7 2)", "3"},
+		{"(/ -7 2)", "-3"},
+		{"(MOD 7 3)", "1"},
+		{"(MOD -7 3)", "2"},
+		{"(MOD 7 -3)", "-2"},
+		{"(ABS -4)", "4"},
+		{"(MIN 3)", "3"},
+		{"(MIN 3 1 2)", "1"},
+		{"(MAX 3 5 2)", "5"},
+		{"(+ 9223372036854775806 1)", "9223372036854775807"},
+		{"(- -9223372036854775807 1)", "-9223372036854775808"},
+		{"(- 9223372036854775807)", "-9223372036854775807"},
+		{"(* -4611686018427387904 2)", "-9223372036854775808"},
+		{"(* 0 -9223372036854775808)", "0"},
+		{"(/ -9223372036854775808 1)", "-9223372036854775808"},
+
+		{"(= 1 1 1)", "#t"},
+		{"(= 1 2)", "#f"},
+		{"(< 1 2 3)", "#t"},
+		{"(< 1 3 2)", "#f"},
+		{"(>= 3 3 1)", "#t"},
+		{"(EQUAL? (QUOTE (a b)) (QUOTE (a b)))", "#t"},
+		{"(EQUAL? 1 2)", "#f"},
+
+		{"(NOT ())", "#t"},
+		{"(NOT 1)", "#f"},
+		{"(NOT #f)", "#t"},
+		{"(AND #t #f 1)", "#f"},
+		{"(AND)", "#t"},
+		{"(AND 1 2)", "2"},
+		{"(AND () undefined)", "()"},
+		{"(OR)", "#f"},
+		{"(OR () 2 undefined)", "2"},
+
+		{`(STRING-APPEND "a" "bc" "")`, `"abc"`},
+		{`(STRING-LENGTH "äöü")`, "3"},
+		{`(SUBSTRING "häuser" 1 3)`, `"äu"`},
+		{`(SUBSTRING "häuser" 2)`, `"user"`},
+		{`(STRING-REF "häuser" 1)`, `#\ä`},
+		{`(STRING-UPCASE "abc")`, `"ABC"`},
+		{`(STRING-DOWNCASE "ABC")`, `"abc"`},
+		{`(STRING-SPLIT "a,b,,c" ",")`, `("a" "b" "" "c")`},
+		{`(STRING-JOIN (QUOTE ("a" "b" "c")) "-")`, `"a-b-c"`},
+		{`(STRING-JOIN (VECTOR "a" "b"))`, `"ab"`},
+		{`(REGEXP-MATCH "(a+)(b*)" "xaab")`, `("aab" "aa" "b")`},
+		{`(REGEXP-MATCH "z" "xaab")`, "()"},
+
+		{"(BYTES-LENGTH #u8(1 2 3))", "3"},
+		{"(BYTES-REF #u8(1 2 3) 2)", "3"},
+
+		{"(CONS 1 2)", "(1 . 2)"},
+		{"(CONS 1 ())", "(1)"},
+		{"(CAR (QUOTE (1 2)))", "1"},
+		{"(CDR (QUOTE (1 2)))", "(2)"},
+		{"(CDR (QUOTE (1)))", "()"},
+		{"(LIST 1 2)", "(1 2)"},
+		{"(VECTOR 1 2)", "[1 2]"},
+		{"(LENGTH (QUOTE (1 2 3)))", "3"},
+		{"(LENGTH [])", "0"},
+		{"(NTH (VECTOR 1 2 3) 1)", "2"},
+		{"(APPEND (QUOTE (1 2)) (VECTOR 3) ())", "(1 2 3)"},
+		{"(REVERSE (QUOTE (1 2 3)))", "(3 2 1)"},
+		{"(REVERSE (VECTOR 1 2 3))", "[3 2 1]"},
+
+		{"(NULL? ())", "#t"},
+		{"(NULL? #f)", "#f"},
+		{"(BOOLEAN? #f)", "#t"},
+		{"(BOOLEAN? ())", "#f"},
+		{"(PAIR? ())", "#f"},
+		{"(PAIR? (QUOTE (1)))", "#t"},
+		{"(LIST? ())", "#t"},
+		{"(LIST? (CONS 1 2))", "#f"},
+		{"(VECTOR? [])", "#t"},
+		{"(SYMBOL? (QUOTE a))", "#t"},
+		{`(STRING? "a")`, "#t"},
+		{"(INTEGER? 1)", "#t"},
+		{"(FORM? CAR)", "#t"},
+		{"(INTEGER->STRING 17)", `"17"`},
+		{`(STRING->INTEGER "-17")`, "-17"},
+		{"(SYMBOL->STRING (QUOTE a))", `"A"`},
+		{`(STRING->SYMBOL "b")`, "B"},
+		{`(CHAR? #\a)`, "#t"},
+		{`(CHAR? "a")`, "#f"},
+		{`(CHAR->INTEGER #\A)`, "65"},
+		{"(INTEGER->CHAR 97)", `#\a`},
+		{`(STRING->LIST "ab")`, `(#\a #\b)`},
+		{`(LIST->STRING (LIST #\a #\space))`, `"a "`},
+		{"(BYTES? #u8())", "#t"},
+		{`(STRING->BYTES "hi")`, `#base64"aGk="`},
+		{"(BYTES->STRING #u8(104 105))", `"hi"`},
+		{"(LIST->VECTOR (QUOTE (1 2)))", "[1 2]"},
+		{"(VECTOR->LIST (VECTOR 1 2))", "(1 2)"},
+
+		{"(GET (QUOTE a) :color)", "()"},
+		{"(GET (QUOTE a) :color 0)", "0"},
+		{"(BEGIN (PUT (QUOTE a) :color 1) (GET (QUOTE a) :color))", "1"},
+		{"(BEGIN (PUT (QUOTE a) :x 1) (PUT (QUOTE a) :y 2) (PUT (QUOTE a) :x 3) (SYMBOL-PLIST (QUOTE a)))", "(:X 3 :Y 2)"},
+		{"(BEGIN (PUT (QUOTE a) :x 1) (LIST (REMPROP (QUOTE a) :x) (REMPROP (QUOTE a) :x)))", "(#t #f)"},
+	}
+	for i, tc := range testcases {
+		engine := newTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should evaluate to %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+		if val, err = runCompiled(newTestEngine(), tc.src); err != nil || val.String() != tc.exp {
+			t.Errorf("%d: compiled %v should evaluate to %v, but got: %v / %v", i, tc.src, tc.exp, val, err)
+		}
+	}
+}
+
+func TestBuiltinsError(t *testing.T) {
+	testcases := []struct {
+		src string
+		msg string
+	}{
+		{"(/ 1 0)", "division by zero"},
+		{"(MOD 1 0)", "division by zero"},
+		{"(+ 9223372036854775807 1)", "integer overflow"},
+		{"(+ -9223372036854775808 -1)", "integer overflow"},
+		{"(- -9223372036854775808)", "integer overflow"},
+		{"(- 9223372036854775807 -1)", "integer overflow"},
+		{"(* 4611686018427387904 2)", "integer overflow"},
+		{"(* -9223372036854775808 -1)", "integer overflow"},
+		{"(/ -9223372036854775808 -1)", "integer overflow"},
+		{"(ABS -9223372036854775808)", "integer overflow"},
+		{`(+ 1 "a")`, `"a" / 1 is not an integer`},
+		{`(SUBSTRING "abc" 2 5)`, `SUBSTRING range 2..5 out of bounds for "abc"`},
+		{"(CAR ())", "() / 0 is not a pair"},
+		{"(NTH (VECTOR 1) 1)", "index 1 out of bounds for [1]"},
+		{"(LENGTH (QUOTE (1 2 . 3)))", "(1 2 . 3) is not a proper list"},
+		{"(NTH (QUOTE (1 . 2)) 1)", "(1 . 2) is not a proper list"},
+		{"(APPEND () (QUOTE (1 . 2)))", "(1 . 2) is not a proper list"},
+		{"(REVERSE (QUOTE (1 2 . 3)))", "(1 2 . 3) is not a proper list"},
+		{"(LIST->VECTOR (QUOTE (1 . 2)))", "(1 . 2) is not a proper list"},
+		{`(STRING-JOIN (QUOTE ("a" . "b")))`, `("a" . "b") is not a proper list`},
+		{`(STRING-REF "abc" 3)`, `index 3 out of bounds for "abc"`},
+		{"(INTEGER->CHAR -1)", "-1 is not a character code"},
+		{"(INTEGER->CHAR 55296)", "55296 is not a character code"},
+		{"(INTEGER->CHAR 1114112)", "1114112 is not a character code"},
+		{`(LIST->STRING (LIST #\a "b"))`, `"b" / 1 is not a character`},
+		{"(BYTES-REF #u8(1) 1)", `index 1 out of bounds for #base64"AQ=="`},
+		{"(BYTES->STRING #u8(255))", `#base64"/w==" is not UTF-8 encoded`},
+		{`(STRING->INTEGER "x")`, `"x" is not an integer`},
+		{`(STRING->SYMBOL "")`, `cannot make a symbol named ""`},
+		{`(SYMBOL? (STRING->SYMBOL ""))`, `cannot make a symbol named ""`},
+		{`(REGEXP-MATCH "(" "x")`, "error parsing regexp: missing closing ): `(`"},
+	}
+	for i, tc := range testcases {
+		engine := newTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		if err == nil {
+			t.Errorf("%d: %v should result in error, but got: %v", i, tc.src, val)
+			continue
+		}
+		if got := err.Error(); got != tc.msg {
+			t.Errorf("%d: %v should result in error %q, but got %q", i, tc.src, tc.msg, got)
+		}
+		if val, err = runCompiled(newTestEngine(), tc.src); err == nil || err.Error() != tc.msg {
+			t.Errorf("%d: compiled %v should result in error %q, but got: %v / %v", i, tc.src, tc.msg, val, err)
+		}
+	}
+}
+
+func TestRegisterGroups(t *testing.T) {
+	engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+	builtins.Register(engine, builtins.Numbers)
+	if _, found := engine.SymbolMap().Lookup(engine.MakeSymbol("+")); !found {
+		t.Error("+ should be bound")
+	}
+	if _, found := engine.SymbolMap().Lookup(engine.MakeSymbol("CAR")); found {
+		t.Error("CAR should not be bound")
+	}
+}
+
+func TestRegisterBasicEnvironment(t *testing.T) {
+	env := sxpf.NewBasicEnvironment(nil, sxpf.EvalStrategy{})
+	builtins.Register(env)
+	expr, err := sxpf.ParseString(env, `(STRING->SYMBOL (STRING-APPEND "a" (INTEGER->STRING (+ 1 2))))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := env.Eval(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := val.String(); got != "A3" {
+		t.Errorf("expected A3, but got %v", got)
+	}
+}
+
+func TestSymbolPropsPerEngine(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	var engines [2]*sxpf.Engine
+	for i := range engines {
+		engines[i] = sxpf.NewEngine(smk)
+		builtins.Register(engines[i], builtins.Symbols)
+	}
+	for i, src := range []string{"(PUT (QUOTE a) :color 1)", "(GET (QUOTE a) :color)"} {
+		expr, err := sxpf.ParseString(engines[i], src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := engines[i].Eval(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && !sxpf.Nil().Equal(val) {
+			t.Errorf("properties must not be shared between engines, but got %v", val)
+		}
+	}
+}
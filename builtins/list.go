@@ -0,0 +1,167 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package builtins
+
+import (
+	"fmt"
+
+	"github.com/t73fde/sxpf"
+)
+
+// Lists contains builtins to work with pair lists, vectors, and other
+// sequences.
+var Lists = []*sxpf.Builtin{
+	sxpf.NewBuiltin("CONS", false, 2, 2, consFn),
+	sxpf.NewBuiltin("CAR", false, 1, 1, carFn),
+	sxpf.NewBuiltin("CDR", false, 1, 1, cdrFn),
+	sxpf.NewBuiltin("LIST", false, 0, -1, listFn),
+	sxpf.NewBuiltin("VECTOR", false, 0, -1, vectorFn),
+	sxpf.NewBuiltin("LENGTH", false, 1, 1, lengthFn).WithPure(),
+	sxpf.NewBuiltin("NTH", false, 2, 2, nthFn),
+	sxpf.NewBuiltin("APPEND", false, 0, -1, appendFn),
+	sxpf.NewBuiltin("REVERSE", false, 1, 1, reverseFn),
+}
+
+func getPair(args []sxpf.Value, idx int) (*sxpf.Pair, error) {
+	if p, ok := args[idx].(*sxpf.Pair); ok && p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("%v / %d is not a pair", args[idx], idx)
+}
+
+// (CONS a b) creates a new pair.
+func consFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	if err := sxpf.Allocate(env, 1); err != nil {
+		return nil, err
+	}
+	return sxpf.NewPair(args[0], args[1]), nil
+}
+
+// (CAR p) returns the first value of the pair.
+func carFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	p, err := getPair(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetFirst(), nil
+}
+
+// (CDR p) returns the second value of the pair.
+func cdrFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	p, err := getPair(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if second := p.GetSecond(); second != nil {
+		return second, nil
+	}
+	return sxpf.Nil(), nil
+}
+
+// (LIST x...) returns a pair list of all arguments.
+func listFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	if err := sxpf.Allocate(env, len(args)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewPairFromSlice(args), nil
+}
+
+// (VECTOR x...) returns a vector of all arguments.
+func vectorFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	if err := sxpf.Allocate(env, len(args)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewVector(args...), nil
+}
+
+// (LENGTH seq) returns the number of elements of the sequence.
+func lengthFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getElements(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return sxpf.NewInteger(int64(len(vals))), nil
+}
+
+// (NTH seq n) returns the n-th element of the sequence, starting with 0.
+func nthFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getElements(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	n, err := sxpf.GetInteger(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || int64(len(vals)) <= n {
+		return nil, fmt.Errorf("index %d out of bounds for %v", n, args[0])
+	}
+	return vals[n], nil
+}
+
+// getElements returns the elements of the sequence argument. A pair list
+// must be a proper list, i.e. it must not end with a dotted tail.
+func getElements(args []sxpf.Value, idx int) ([]sxpf.Value, error) {
+	seq, err := sxpf.GetSequence(args, idx)
+	if err != nil {
+		return nil, err
+	}
+	p, isPair := seq.(*sxpf.Pair)
+	if !isPair {
+		return seq.GetSlice(), nil
+	}
+	var vals []sxpf.Value
+	for cp := p; cp != nil; {
+		vals = append(vals, cp.GetFirst())
+		np, ok := cp.GetSecond().(*sxpf.Pair)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a proper list", p)
+		}
+		cp = np
+	}
+	return vals, nil
+}
+
+// (APPEND seq...) returns a pair list of the elements of all sequences.
+func appendFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	var result []sxpf.Value
+	for i := range args {
+		vals, err := getElements(args, i)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, vals...)
+	}
+	if err := sxpf.Allocate(env, len(result)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewPairFromSlice(result), nil
+}
+
+// (REVERSE seq) returns a sequence of the same type with the elements in
+// reverse order.
+func reverseFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getElements(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = sxpf.Allocate(env, len(vals)); err != nil {
+		return nil, err
+	}
+	result := make([]sxpf.Value, len(vals))
+	for i, val := range vals {
+		result[len(vals)-1-i] = val
+	}
+	if _, isVector := args[0].(*sxpf.Vector); isVector {
+		return sxpf.NewVector(result...), nil
+	}
+	return sxpf.NewPairFromSlice(result), nil
+}
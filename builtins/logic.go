@@ -0,0 +1,87 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package builtins
+
+import "github.com/t73fde/sxpf"
+
+// Comparisons contains builtins to compare values.
+var Comparisons = []*sxpf.Builtin{
+	sxpf.NewBuiltin("=", false, 1, -1, compareFn(func(a, b int64) bool { return a == b })).WithPure(),
+	sxpf.NewBuiltin("<", false, 1, -1, compareFn(func(a, b int64) bool { return a < b })).WithPure(),
+	sxpf.NewBuiltin(">", false, 1, -1, compareFn(func(a, b int64) bool { return a > b })).WithPure(),
+	sxpf.NewBuiltin("<=", false, 1, -1, compareFn(func(a, b int64) bool { return a <= b })).WithPure(),
+	sxpf.NewBuiltin(">=", false, 1, -1, compareFn(func(a, b int64) bool { return a >= b })).WithPure(),
+	sxpf.NewBuiltin("EQUAL?", false, 2, 2, equalFn).WithPure(),
+}
+
+// Logic contains builtins for boolean logic.
+var Logic = []*sxpf.Builtin{
+	sxpf.NewBuiltin("NOT", false, 1, 1, notFn).WithPure(),
+	sxpf.NewBuiltin("AND", true, 0, -1, andFn),
+	sxpf.NewBuiltin("OR", true, 0, -1, orFn),
+}
+
+// compareFn returns a builtin function, that checks whether all adjacent
+// integer arguments satisfy the given relation.
+func compareFn(rel func(a, b int64) bool) sxpf.BuiltinFn {
+	return func(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+		vals, err := getIntegers(args)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(vals); i++ {
+			if !rel(vals[i-1], vals[i]) {
+				return sxpf.False(), nil
+			}
+		}
+		return sxpf.True(), nil
+	}
+}
+
+// (EQUAL? a b) returns true, if both values are equal.
+func equalFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return sxpf.MakeBoolean(args[0].Equal(args[1])), nil
+}
+
+// (NOT x) returns true, if x is false.
+func notFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return sxpf.MakeBoolean(!sxpf.IsTrue(args[0])), nil
+}
+
+// (AND x...) evaluates all x until one is false. The result is the value of
+// the last evaluated x, or true if there is none.
+func andFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	if len(args) == 0 {
+		return sxpf.True(), nil
+	}
+	for _, arg := range args[:len(args)-1] {
+		val, err := sxpf.Evaluate(env, arg)
+		if err != nil || !sxpf.IsTrue(val) {
+			return val, err
+		}
+	}
+	return sxpf.TailCall(env, args[len(args)-1]), nil
+}
+
+// (OR x...) evaluates all x until one is true. The result is the value of
+// the last evaluated x, or false if there is none.
+func orFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	if len(args) == 0 {
+		return sxpf.False(), nil
+	}
+	for _, arg := range args[:len(args)-1] {
+		val, err := sxpf.Evaluate(env, arg)
+		if err != nil || sxpf.IsTrue(val) {
+			return val, err
+		}
+	}
+	return sxpf.TailCall(env, args[len(args)-1]), nil
+}
@@ -0,0 +1,167 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package builtins
+
+import (
+	"errors"
+	"math"
+
+	"github.com/t73fde/sxpf"
+)
+
+// Numbers contains builtins for integer arithmetic.
+var Numbers = []*sxpf.Builtin{
+	sxpf.NewBuiltin("+", false, 0, -1, addFn).WithPure(),
+	sxpf.NewBuiltin("-", false, 1, -1, subFn).WithPure(),
+	sxpf.NewBuiltin("*", false, 0, -1, mulFn).WithPure(),
+	sxpf.NewBuiltin("/", false, 1, -1, divFn).WithPure(),
+	sxpf.NewBuiltin("MOD", false, 2, 2, modFn).WithPure(),
+	sxpf.NewBuiltin("ABS", false, 1, 1, absFn).WithPure(),
+	sxpf.NewBuiltin("MIN", false, 1, -1, minFn).WithPure(),
+	sxpf.NewBuiltin("MAX", false, 1, -1, maxFn).WithPure(),
+}
+
+// ErrDivisionByZero is returned if an integer is divided by zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// ErrOverflow is returned if the result of an integer operation does not fit
+// into 64 bits.
+var ErrOverflow = errors.New("integer overflow")
+
+func getIntegers(args []sxpf.Value) ([]int64, error) {
+	result := make([]int64, len(args))
+	for i := range args {
+		val, err := sxpf.GetInteger(args, i)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = val
+	}
+	return result, nil
+}
+
+// fold combines all integer arguments with the given function. If there is
+// more than one argument, the first argument is the initial value.
+func fold(args []sxpf.Value, init int64, fn func(acc, val int64) (int64, error)) (sxpf.Value, error) {
+	vals, err := getIntegers(args)
+	if err != nil {
+		return nil, err
+	}
+	acc := init
+	if len(vals) > 1 {
+		acc, vals = vals[0], vals[1:]
+	}
+	for _, val := range vals {
+		if acc, err = fn(acc, val); err != nil {
+			return nil, err
+		}
+	}
+	return sxpf.NewInteger(acc), nil
+}
+
+// (+ n...) returns the sum of all numbers.
+func addFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return fold(args, 0, func(acc, val int64) (int64, error) {
+		if res := acc + val; (res > acc) == (val > 0) {
+			return res, nil
+		}
+		return 0, ErrOverflow
+	})
+}
+
+// (- n) negates n, (- n m...) subtracts all m from n.
+func subFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return fold(args, 0, func(acc, val int64) (int64, error) {
+		if res := acc - val; (res < acc) == (val > 0) {
+			return res, nil
+		}
+		return 0, ErrOverflow
+	})
+}
+
+// (* n...) returns the product of all numbers.
+func mulFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return fold(args, 1, func(acc, val int64) (int64, error) {
+		if acc == 0 || val == 0 {
+			return 0, nil
+		}
+		res := acc * val
+		if res/val != acc || (acc == -1 && val == math.MinInt64) || (val == -1 && acc == math.MinInt64) {
+			return 0, ErrOverflow
+		}
+		return res, nil
+	})
+}
+
+// (/ n) returns 1/n, (/ n m...) divides n by all m. The result is truncated
+// towards zero.
+func divFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return fold(args, 1, func(acc, val int64) (int64, error) {
+		if val == 0 {
+			return 0, ErrDivisionByZero
+		}
+		if acc == math.MinInt64 && val == -1 {
+			return 0, ErrOverflow
+		}
+		return acc / val, nil
+	})
+}
+
+// (MOD n m) returns n modulo m. The result has the sign of m.
+func modFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getIntegers(args)
+	if err != nil {
+		return nil, err
+	}
+	if vals[1] == 0 {
+		return nil, ErrDivisionByZero
+	}
+	m := vals[0] % vals[1]
+	if m != 0 && (m < 0) != (vals[1] < 0) {
+		m += vals[1]
+	}
+	return sxpf.NewInteger(m), nil
+}
+
+// (ABS n) returns the absolute value of n.
+func absFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	val, err := sxpf.GetInteger(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if val == math.MinInt64 {
+		return nil, ErrOverflow
+	}
+	if val < 0 {
+		val = -val
+	}
+	return sxpf.NewInteger(val), nil
+}
+
+// (MIN n...) returns the smallest number.
+func minFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return fold(args, math.MaxInt64, func(acc, val int64) (int64, error) {
+		if val < acc {
+			return val, nil
+		}
+		return acc, nil
+	})
+}
+
+// (MAX n...) returns the greatest number.
+func maxFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	return fold(args, math.MinInt64, func(acc, val int64) (int64, error) {
+		if val > acc {
+			return val, nil
+		}
+		return acc, nil
+	})
+}
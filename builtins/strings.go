@@ -0,0 +1,209 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package builtins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/t73fde/sxpf"
+)
+
+// Strings contains builtins to work with strings. Indexes count unicode
+// characters, not bytes.
+var Strings = []*sxpf.Builtin{
+	sxpf.NewBuiltin("STRING-APPEND", false, 0, -1, stringAppendFn).WithPure(),
+	sxpf.NewBuiltin("STRING-LENGTH", false, 1, 1, stringLengthFn).WithPure(),
+	sxpf.NewBuiltin("STRING-REF", false, 2, 2, stringRefFn).WithPure(),
+	sxpf.NewBuiltin("SUBSTRING", false, 2, 3, substringFn).WithPure(),
+	sxpf.NewBuiltin("STRING-UPCASE", false, 1, 1, stringMapFn(strings.ToUpper)).WithPure(),
+	sxpf.NewBuiltin("STRING-DOWNCASE", false, 1, 1, stringMapFn(strings.ToLower)).WithPure(),
+	sxpf.NewBuiltin("STRING-SPLIT", false, 2, 2, stringSplitFn),
+	sxpf.NewBuiltin("STRING-JOIN", false, 1, 2, stringJoinFn).WithPure(),
+	sxpf.NewBuiltin("REGEXP-MATCH", false, 2, 2, regexpMatchFn),
+	sxpf.NewBuiltin("BYTES-LENGTH", false, 1, 1, bytesLengthFn).WithPure(),
+	sxpf.NewBuiltin("BYTES-REF", false, 2, 2, bytesRefFn).WithPure(),
+}
+
+// (STRING-APPEND s...) concatenates all strings.
+func stringAppendFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	var sb strings.Builder
+	for i := range args {
+		s, err := sxpf.GetString(args, i)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(s)
+	}
+	if err := sxpf.Allocate(env, sb.Len()); err != nil {
+		return nil, err
+	}
+	return sxpf.NewString(sb.String()), nil
+}
+
+// (STRING-LENGTH s) returns the number of characters of s.
+func stringLengthFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return sxpf.NewInteger(int64(len([]rune(s)))), nil
+}
+
+// (STRING-REF s n) returns the n-th character of s, starting with 0.
+func stringRefFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	n, err := sxpf.GetInteger(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	if n < 0 || int64(len(runes)) <= n {
+		return nil, fmt.Errorf("index %d out of bounds for %v", n, args[0])
+	}
+	return sxpf.NewChar(runes[n]), nil
+}
+
+// (SUBSTRING s start end?) returns the characters of s from start up to, but
+// not including end. If end is not given, the rest of s is returned.
+func substringFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, err := sxpf.GetInteger(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	end := int64(len(runes))
+	if len(args) > 2 {
+		if end, err = sxpf.GetInteger(args, 2); err != nil {
+			return nil, err
+		}
+	}
+	if start < 0 || end < start || int64(len(runes)) < end {
+		return nil, fmt.Errorf("SUBSTRING range %d..%d out of bounds for %v", start, end, args[0])
+	}
+	return sxpf.NewString(string(runes[start:end])), nil
+}
+
+// stringMapFn returns a builtin function, that transforms a string.
+func stringMapFn(fn func(string) string) sxpf.BuiltinFn {
+	return func(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+		s, err := sxpf.GetString(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return sxpf.NewString(fn(s)), nil
+	}
+}
+
+// (STRING-SPLIT s sep) returns the list of substrings of s, that are
+// separated by sep.
+func stringSplitFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := sxpf.GetString(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(s, sep)
+	if err = sxpf.Allocate(env, len(parts)); err != nil {
+		return nil, err
+	}
+	result := make([]sxpf.Value, len(parts))
+	for i, part := range parts {
+		result[i] = sxpf.NewString(part)
+	}
+	return sxpf.NewPairFromSlice(result), nil
+}
+
+// (STRING-JOIN seq sep?) concatenates all strings of the sequence, separated
+// by sep.
+func stringJoinFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getElements(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep := ""
+	if len(args) > 1 {
+		if sep, err = sxpf.GetString(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	parts := make([]string, len(vals))
+	for i := range vals {
+		if parts[i], err = sxpf.GetString(vals, i); err != nil {
+			return nil, err
+		}
+	}
+	result := strings.Join(parts, sep)
+	if err = sxpf.Allocate(env, len(result)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewString(result), nil
+}
+
+// (REGEXP-MATCH pattern s) matches s against the regular expression. The
+// result is the list of the matched string and all sub-matches, or the empty
+// list if s does not match.
+func regexpMatchFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	pattern, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	s, err := sxpf.GetString(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches := re.FindStringSubmatch(s)
+	result := make([]sxpf.Value, len(matches))
+	for i, m := range matches {
+		result[i] = sxpf.NewString(m)
+	}
+	return sxpf.NewPairFromSlice(result), nil
+}
+
+// (BYTES-LENGTH b) returns the number of bytes of the byte string.
+func bytesLengthFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	b, err := sxpf.GetBytes(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return sxpf.NewInteger(int64(len(b))), nil
+}
+
+// (BYTES-REF b n) returns the n-th byte of the byte string, starting with 0.
+func bytesRefFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	b, err := sxpf.GetBytes(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	n, err := sxpf.GetInteger(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || int64(len(b)) <= n {
+		return nil, fmt.Errorf("index %d out of bounds for %v", n, args[0])
+	}
+	return sxpf.NewInteger(int64(b[n])), nil
+}
@@ -0,0 +1,88 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package builtins
+
+import (
+	"fmt"
+
+	"github.com/t73fde/sxpf"
+)
+
+// Symbols contains builtins to work with the property lists of symbols. The
+// property lists are stored in the Metadata of the environment, e.g. of an
+// Engine.
+var Symbols = []*sxpf.Builtin{
+	sxpf.NewBuiltin("GET", false, 2, 3, getFn),
+	sxpf.NewBuiltin("PUT", false, 3, 3, putFn),
+	sxpf.NewBuiltin("REMPROP", false, 2, 2, rempropFn),
+	sxpf.NewBuiltin("SYMBOL-PLIST", false, 1, 1, symbolPlistFn),
+}
+
+// getSymbolMetadata returns the symbol argument and the metadata of the
+// environment.
+func getSymbolMetadata(env sxpf.Environment, args []sxpf.Value) (*sxpf.Symbol, *sxpf.Metadata, error) {
+	sym, err := sxpf.GetSymbol(args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if me, ok := env.(interface{ Metadata() *sxpf.Metadata }); ok {
+		return sym, me.Metadata(), nil
+	}
+	return nil, nil, fmt.Errorf("environment %T has no metadata", env)
+}
+
+// (GET sym key default?) returns the property key of the symbol. If it is not
+// set, default is returned, which defaults to the empty list.
+func getFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	sym, md, err := getSymbolMetadata(env, args)
+	if err != nil {
+		return nil, err
+	}
+	if val, found := md.GetProp(sym, args[1]); found {
+		return val, nil
+	}
+	if len(args) > 2 {
+		return args[2], nil
+	}
+	return sxpf.Nil(), nil
+}
+
+// (PUT sym key val) sets the property key of the symbol and returns val.
+func putFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	sym, md, err := getSymbolMetadata(env, args)
+	if err != nil {
+		return nil, err
+	}
+	if err = sxpf.Allocate(env, 1); err != nil {
+		return nil, err
+	}
+	md.PutProp(sym, args[1], args[2])
+	return args[2], nil
+}
+
+// (REMPROP sym key) removes the property key of the symbol. It returns true,
+// if the property was set.
+func rempropFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	sym, md, err := getSymbolMetadata(env, args)
+	if err != nil {
+		return nil, err
+	}
+	return sxpf.MakeBoolean(md.RemoveProp(sym, args[1])), nil
+}
+
+// (SYMBOL-PLIST sym) returns the property list of the symbol.
+func symbolPlistFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	sym, md, err := getSymbolMetadata(env, args)
+	if err != nil {
+		return nil, err
+	}
+	return md.Props(sym), nil
+}
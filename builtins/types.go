@@ -0,0 +1,238 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package builtins
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/t73fde/sxpf"
+)
+
+// Types contains builtins to check the type of a value and to convert
+// values into other types.
+var Types = []*sxpf.Builtin{
+	predicate("NULL?", func(val sxpf.Value) bool {
+		p, ok := val.(*sxpf.Pair)
+		return ok && p == nil
+	}),
+	predicate("PAIR?", func(val sxpf.Value) bool {
+		p, ok := val.(*sxpf.Pair)
+		return ok && p != nil
+	}),
+	predicate("LIST?", isList),
+	predicate("VECTOR?", func(val sxpf.Value) bool {
+		_, ok := val.(*sxpf.Vector)
+		return ok
+	}),
+	predicate("BOOLEAN?", func(val sxpf.Value) bool {
+		_, ok := val.(*sxpf.Boolean)
+		return ok
+	}),
+	predicate("SYMBOL?", func(val sxpf.Value) bool {
+		_, ok := val.(*sxpf.Symbol)
+		return ok
+	}),
+	predicate("STRING?", func(val sxpf.Value) bool {
+		_, ok := val.(*sxpf.String)
+		return ok
+	}),
+	predicate("CHAR?", func(val sxpf.Value) bool {
+		_, ok := val.(*sxpf.Char)
+		return ok
+	}),
+	predicate("BYTES?", func(val sxpf.Value) bool {
+		_, ok := val.(*sxpf.Bytes)
+		return ok
+	}),
+	predicate("INTEGER?", func(val sxpf.Value) bool {
+		_, ok := val.(*sxpf.Integer)
+		return ok
+	}),
+	predicate("FORM?", func(val sxpf.Value) bool {
+		_, ok := val.(sxpf.Form)
+		return ok
+	}),
+	sxpf.NewBuiltin("INTEGER->STRING", false, 1, 1, integerToStringFn).WithPure(),
+	sxpf.NewBuiltin("STRING->INTEGER", false, 1, 1, stringToIntegerFn).WithPure(),
+	sxpf.NewBuiltin("SYMBOL->STRING", false, 1, 1, symbolToStringFn).WithPure(),
+	sxpf.NewBuiltin("STRING->SYMBOL", false, 1, 1, stringToSymbolFn),
+	sxpf.NewBuiltin("CHAR->INTEGER", false, 1, 1, charToIntegerFn).WithPure(),
+	sxpf.NewBuiltin("INTEGER->CHAR", false, 1, 1, integerToCharFn).WithPure(),
+	sxpf.NewBuiltin("STRING->LIST", false, 1, 1, stringToListFn),
+	sxpf.NewBuiltin("LIST->STRING", false, 1, 1, listToStringFn).WithPure(),
+	sxpf.NewBuiltin("STRING->BYTES", false, 1, 1, stringToBytesFn),
+	sxpf.NewBuiltin("BYTES->STRING", false, 1, 1, bytesToStringFn).WithPure(),
+	sxpf.NewBuiltin("LIST->VECTOR", false, 1, 1, listToVectorFn),
+	sxpf.NewBuiltin("VECTOR->LIST", false, 1, 1, vectorToListFn),
+}
+
+// isList returns true, if the value is a proper pair list.
+func isList(val sxpf.Value) bool {
+	p, ok := val.(*sxpf.Pair)
+	for ok && p != nil {
+		p, ok = p.GetSecond().(*sxpf.Pair)
+	}
+	return ok
+}
+
+// (INTEGER->STRING n) returns the decimal representation of n.
+func integerToStringFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	if _, err := sxpf.GetInteger(args, 0); err != nil {
+		return nil, err
+	}
+	return sxpf.NewString(args[0].String()), nil
+}
+
+// (STRING->INTEGER s) parses s as an integer.
+func stringToIntegerFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := sxpf.ParseInteger(s); ok {
+		return i, nil
+	}
+	return nil, fmt.Errorf("%q is not an integer", s)
+}
+
+// (SYMBOL->STRING sym) returns the name of the symbol.
+func symbolToStringFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	sym, err := sxpf.GetSymbol(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return sxpf.NewString(sym.GetValue()), nil
+}
+
+// (STRING->SYMBOL s) returns the symbol with the name s.
+func stringToSymbolFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	smk, ok := env.(sxpf.SymbolMaker)
+	if !ok {
+		return nil, fmt.Errorf("environment %v cannot make symbols", env)
+	}
+	if sym := smk.MakeSymbol(s); sym != nil {
+		return sym, nil
+	}
+	return nil, fmt.Errorf("cannot make a symbol named %q", s)
+}
+
+// (CHAR->INTEGER c) returns the code of the character.
+func charToIntegerFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	r, err := sxpf.GetChar(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return sxpf.NewInteger(int64(r)), nil
+}
+
+// (INTEGER->CHAR n) returns the character with the code n.
+func integerToCharFn(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	n, err := sxpf.GetInteger(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > unicode.MaxRune || !utf8.ValidRune(rune(n)) {
+		return nil, fmt.Errorf("%d is not a character code", n)
+	}
+	return sxpf.NewChar(rune(n)), nil
+}
+
+// (STRING->LIST s) returns the list of the characters of s.
+func stringToListFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	if err = sxpf.Allocate(env, len(runes)); err != nil {
+		return nil, err
+	}
+	result := make([]sxpf.Value, len(runes))
+	for i, r := range runes {
+		result[i] = sxpf.NewChar(r)
+	}
+	return sxpf.NewPairFromSlice(result), nil
+}
+
+// (LIST->STRING seq) returns the string of the characters of the sequence.
+func listToStringFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getElements(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := make([]rune, len(vals))
+	for i := range vals {
+		if runes[i], err = sxpf.GetChar(vals, i); err != nil {
+			return nil, err
+		}
+	}
+	if err = sxpf.Allocate(env, len(runes)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewString(string(runes)), nil
+}
+
+// (STRING->BYTES s) returns the UTF-8 encoding of s.
+func stringToBytesFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	s, err := sxpf.GetString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = sxpf.Allocate(env, len(s)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewBytes([]byte(s)), nil
+}
+
+// (BYTES->STRING b) returns the string, that is UTF-8 encoded in b.
+func bytesToStringFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	b, err := sxpf.GetBytes(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("%v is not UTF-8 encoded", args[0])
+	}
+	if err = sxpf.Allocate(env, len(b)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewString(string(b)), nil
+}
+
+// (LIST->VECTOR seq) returns a vector of the elements of the sequence.
+func listToVectorFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getElements(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = sxpf.Allocate(env, len(vals)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewVector(vals...), nil
+}
+
+// (VECTOR->LIST seq) returns a pair list of the elements of the sequence.
+func vectorToListFn(env sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+	vals, err := getElements(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = sxpf.Allocate(env, len(vals)); err != nil {
+		return nil, err
+	}
+	return sxpf.NewPairFromSlice(vals), nil
+}
@@ -0,0 +1,94 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+)
+
+// Bytes is a sequence of arbitrary bytes, e.g. a hash value or the content
+// of an image. It is written as a list of decimal byte values, e.g.
+// "#u8(1 2 255)", or as a base64 encoded string, e.g. `#base64"AQL/"`.
+//
+// Bytes implements encoding.BinaryMarshaler and encoding.TextMarshaler, and
+// the corresponding unmarshaler interfaces.
+type Bytes struct {
+	val []byte
+}
+
+// NewBytes creates a new byte string. The given slice must not be modified
+// afterwards.
+func NewBytes(val []byte) *Bytes { return &Bytes{val} }
+
+// GetValue returns the bytes. They must not be modified.
+func (b *Bytes) GetValue() []byte { return b.val }
+
+// Equal retruns true if the other value is equal to this one.
+func (b *Bytes) Equal(other Value) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+	if o, ok := other.(*Bytes); ok {
+		return bytes.Equal(b.val, o.val)
+	}
+	return false
+}
+
+// String returns the base64 encoded bytes, in a form that can be parsed
+// again.
+func (b *Bytes) String() string {
+	return `#base64"` + base64.StdEncoding.EncodeToString(b.val) + `"`
+}
+
+// Value returns the bytes as a string.
+func (b *Bytes) Value() string { return string(b.val) }
+
+// MarshalBinary returns a copy of the bytes.
+func (b *Bytes) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), b.val...), nil
+}
+
+// UnmarshalBinary sets the bytes to a copy of data.
+func (b *Bytes) UnmarshalBinary(data []byte) error {
+	b.val = append([]byte(nil), data...)
+	return nil
+}
+
+// MarshalText returns the bytes base64 encoded.
+func (b *Bytes) MarshalText() ([]byte, error) {
+	result := make([]byte, base64.StdEncoding.EncodedLen(len(b.val)))
+	base64.StdEncoding.Encode(result, b.val)
+	return result, nil
+}
+
+// UnmarshalText sets the bytes to the base64 decoded text.
+func (b *Bytes) UnmarshalText(text []byte) error {
+	val := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(val, text)
+	if err != nil {
+		return err
+	}
+	b.val = val[:n]
+	return nil
+}
+
+// GetBytes returns the idx value of args as a byte slice.
+func GetBytes(args []Value, idx int) ([]byte, error) {
+	if idx < 0 || len(args) <= idx {
+		return nil, makeErrIndexOutOfBounds(args, idx)
+	}
+	if val, ok := args[idx].(*Bytes); ok {
+		return val.GetValue(), nil
+	}
+	return nil, fmt.Errorf("%v / %d is not a byte string", args[idx], idx)
+}
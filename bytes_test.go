@@ -0,0 +1,60 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestBytes(t *testing.T) {
+	data := []byte{0, 1, 127, 128, 255}
+	b := sxpf.NewBytes(data)
+	if !b.Equal(sxpf.NewBytes([]byte{0, 1, 127, 128, 255})) {
+		t.Error("bytes with same content must be equal")
+	}
+	if b.Equal(sxpf.NewString(string(data))) {
+		t.Error("bytes must not be equal to a string")
+	}
+
+	bin, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got sxpf.Bytes
+	if err = got.UnmarshalBinary(bin); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.GetValue(), data) {
+		t.Errorf("binary codec: expected %v, but got %v", data, got.GetValue())
+	}
+
+	text, err := b.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = got.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.GetValue(), data) {
+		t.Errorf("text codec: expected %v, but got %v", data, got.GetValue())
+	}
+
+	val, err := sxpf.ParseString(sxpf.NewTrivialSymbolMaker(), b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equal(val) {
+		t.Errorf("%v should be parsed to itself, but got %v", b, val)
+	}
+}
@@ -0,0 +1,92 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Char is a single unicode character. It is written as "#\" followed by the
+// character, e.g. "#\a", by its name, e.g. "#\space", or by its hexadecimal
+// code, e.g. "#\x41", "#\u00e4", or "#\U01f600".
+type Char struct {
+	val rune
+}
+
+// NewChar creates a new character.
+func NewChar(r rune) *Char { return &Char{r} }
+
+// GetValue returns the rune of the character.
+func (c *Char) GetValue() rune { return c.val }
+
+// Equal retruns true if the other value is equal to this one.
+func (c *Char) Equal(other Value) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if o, ok := other.(*Char); ok {
+		return c.val == o.val
+	}
+	return false
+}
+
+func (c *Char) String() string {
+	for name, r := range CharNames {
+		if r == c.val {
+			return "#\\" + name
+		}
+	}
+	if unicode.IsGraphic(c.val) && !unicode.IsSpace(c.val) {
+		return "#\\" + string(c.val)
+	}
+	switch {
+	case c.val <= 0xff:
+		return fmt.Sprintf("#\\x%02x", c.val)
+	case c.val <= 0xffff:
+		return fmt.Sprintf("#\\u%04x", c.val)
+	}
+	return fmt.Sprintf("#\\U%06x", c.val)
+}
+
+// Value returns the character as a string.
+func (c *Char) Value() string { return string(c.val) }
+
+// CharNames maps the names of characters to the characters. Names are
+// case-insensitive.
+var CharNames = map[string]rune{
+	"nul":       0,
+	"alarm":     7,
+	"backspace": 8,
+	"tab":       '\t',
+	"newline":   '\n',
+	"return":    '\r',
+	"escape":    0x1b,
+	"space":     ' ',
+	"delete":    0x7f,
+}
+
+func lookupCharName(name string) (rune, bool) {
+	r, found := CharNames[strings.ToLower(name)]
+	return r, found
+}
+
+// GetChar returns the idx value of args as a rune.
+func GetChar(args []Value, idx int) (rune, error) {
+	if idx < 0 || len(args) <= idx {
+		return 0, makeErrIndexOutOfBounds(args, idx)
+	}
+	if val, ok := args[idx].(*Char); ok {
+		return val.GetValue(), nil
+	}
+	return 0, fmt.Errorf("%v / %d is not a character", args[idx], idx)
+}
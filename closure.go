@@ -0,0 +1,147 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import "fmt"
+
+// Closure is a form created by evaluating a LAMBDA expression. It captures
+// the scope it was created in.
+type Closure struct {
+	name   string
+	params []*Symbol
+	rest   *Symbol // if not nil, it is bound to the list of remaining arguments
+	body   []Value
+	env    *Engine
+	doc    string
+	proto  *proto   // if not nil, the closure was compiled
+	frame  *vmFrame // the captured frame of a compiled closure
+}
+
+// Name returns the name of the closure. An anonymous closure has an empty name.
+func (c *Closure) Name() string {
+	if c == nil {
+		return ""
+	}
+	return c.name
+}
+
+// Doc returns the docstring of the closure, i.e. the first value of its
+// body, if it is a string followed by other values.
+func (c *Closure) Doc() string {
+	if c == nil {
+		return ""
+	}
+	return c.doc
+}
+
+func (c *Closure) Equal(other Value) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if o, ok := other.(*Closure); ok {
+		return c == o
+	}
+	return false
+}
+
+func (c *Closure) String() string {
+	if c.name == "" {
+		return "#<closure>"
+	}
+	return "#<closure " + c.name + ">"
+}
+
+func (*Closure) IsSpecial() bool { return false }
+
+// Call the closure: bind the arguments to the parameters in a new scope,
+// nested in the captured scope, and evaluate the body there. The last value
+// of the body is evaluated as a tail call.
+func (c *Closure) Call(_ Environment, args []Value) (Value, error) {
+	numParams := len(c.params)
+	if length := len(args); length < numParams {
+		return nil, fmt.Errorf("not enough arguments (%d) for form %v (%d)", length, c, numParams)
+	} else if c.rest == nil && numParams < length {
+		return nil, fmt.Errorf("too many arguments (%d) for form %v (%d)", length, c, numParams)
+	}
+	if err := c.env.state.allocate(len(args) + 1); err != nil {
+		return nil, err
+	}
+	if p := c.proto; p != nil {
+		frame := &vmFrame{parent: c.frame, slots: make([]Value, p.size)}
+		copy(frame.slots, args[:numParams])
+		if c.rest != nil {
+			frame.slots[numParams] = NewPairFromSlice(args[numParams:])
+		}
+		return p.body.run(c.env, frame)
+	}
+	scope := c.env.newLocal()
+	for i, sym := range c.params {
+		scope.Define(sym, args[i])
+	}
+	if c.rest != nil {
+		scope.Define(c.rest, NewPairFromSlice(args[numParams:]))
+	}
+	return evaluateBody(scope, c.body)
+}
+
+// evaluateBody evaluates all values, except the last one. The last one is
+// returned as a tail call.
+func evaluateBody(env Environment, body []Value) (Value, error) {
+	if len(body) == 0 {
+		return Nil(), nil
+	}
+	last := len(body) - 1
+	for _, val := range body[:last] {
+		if _, err := Evaluate(env, val); err != nil {
+			return nil, err
+		}
+	}
+	return TailCall(env, body[last]), nil
+}
+
+// parseParams analyzes the parameter specification of a LAMBDA form. It is
+// either a symbol that will be bound to all arguments, a possibly dotted list
+// of symbols, or a vector of symbols.
+func parseParams(spec Value) (params []*Symbol, rest *Symbol, err error) {
+	switch s := spec.(type) {
+	case *Symbol:
+		return nil, s, nil
+	case *Vector:
+		for _, val := range s.GetSlice() {
+			sym, ok := val.(*Symbol)
+			if !ok {
+				return nil, nil, fmt.Errorf("parameter %v is not a symbol", val)
+			}
+			params = append(params, sym)
+		}
+		return params, nil, nil
+	case *Pair:
+		for cp := s; cp != nil; {
+			sym, ok := cp.GetFirst().(*Symbol)
+			if !ok {
+				return nil, nil, fmt.Errorf("parameter %v is not a symbol", cp.GetFirst())
+			}
+			params = append(params, sym)
+			switch next := cp.GetSecond().(type) {
+			case *Pair:
+				cp = next
+			case *Symbol:
+				return params, next, nil
+			case nil:
+				cp = nil
+			default:
+				return nil, nil, fmt.Errorf("parameter %v is not a symbol", next)
+			}
+		}
+		return params, nil, nil
+	}
+	return nil, nil, fmt.Errorf("%v is not a parameter list", spec)
+}
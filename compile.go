@@ -0,0 +1,854 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Code is a value compiled into bytecode by Engine.Compile.
+type Code struct {
+	e        *Engine
+	blk      *block
+	compiled bool
+}
+
+// Compile expands all macro calls of the given value and compiles the result
+// into bytecode. Running the code returns the same result as Eval, but
+// faster, especially if the code is run many times.
+//
+// Local variables are addressed by their position in a frame, instead of
+// being looked up by their symbol. The special forms QUOTE, IF, COND, BEGIN,
+// DEFINE, SET!, LAMBDA, and LET are translated into instructions, if their
+// symbols are bound to the core forms of the engine when the value is
+// compiled. Calls of pure builtins (see Builtin.WithPure) with constant
+// arguments are evaluated when the value is compiled. This is not done for
+// symbols, that are bound by DEFINE or SET! within the value. If such a
+// symbol is bound to another value when the code is run, the call is
+// evaluated like any other call.
+//
+// If the value cannot be compiled, e.g. because it contains a DEFMACRO or a
+// HANDLER-CASE within a LAMBDA, the code evaluates it with the interpreter.
+func (e *Engine) Compile(val Value) (*Code, error) {
+	exp, err := e.Expand(val)
+	if err != nil {
+		return nil, err
+	}
+	c := newCompiler(e, nil)
+	c.collectAssigned(exp)
+	if err = c.compile(exp, true); err != nil {
+		var nce *notCompilableError
+		if !errors.As(err, &nce) {
+			return nil, err
+		}
+		return &Code{e: e, blk: &block{code: []instr{{op: opInterp}}, consts: []Value{exp}}}, nil
+	}
+	return &Code{e: e, blk: c.blk, compiled: true}, nil
+}
+
+// IsCompiled returns false, if the code is evaluated by the interpreter,
+// because its value could not be compiled.
+func (code *Code) IsCompiled() bool { return code.compiled }
+
+// Run executes the code in the scope of the engine it was compiled by.
+func (code *Code) Run() (Value, error) { return code.RunContext(context.Background()) }
+
+// RunContext executes the code in the scope of the engine it was compiled by.
+// The execution stops with a CanceledError, if the context is done. Limits
+// are handled like in Engine.EvalContext.
+func (code *Code) RunContext(ctx context.Context) (Value, error) {
+	defer code.e.state.start(ctx)()
+	return resolveTailCall(code.blk.run(code.e, nil))
+}
+
+// String returns a listing of the instructions of the code.
+func (code *Code) String() string {
+	var sb strings.Builder
+	code.blk.disassemble(&sb, "")
+	return sb.String()
+}
+
+func (blk *block) disassemble(sb *strings.Builder, indent string) {
+	for pc, in := range blk.code {
+		fmt.Fprintf(sb, "%s%04d %v", indent, pc, in.op)
+		switch in.op {
+		case opConst, opGlobal, opCheckBound, opSetGlobal, opDefGlobal, opNotCall, opInterp:
+			fmt.Fprintf(sb, " %v", blk.consts[in.a])
+		case opLocal, opSetLocal:
+			fmt.Fprintf(sb, " %d %d", in.a, in.b)
+		case opLocalDef, opSetLocalDef:
+			fmt.Fprintf(sb, " %d %d %04d", in.a, in.b, in.c)
+		case opDefLocal:
+			fmt.Fprintf(sb, " %d %v", in.a, blk.consts[in.b])
+		case opJump, opJumpFalse, opJumpTrue:
+			fmt.Fprintf(sb, " %04d", in.a)
+		case opGuard:
+			fmt.Fprintf(sb, " %v %04d", blk.guards[in.a].sym, in.c)
+		case opClosure, opAlloc, opEnter:
+			fmt.Fprintf(sb, " %d", in.a)
+		case opHead, opCheckHead, opCall, opTailCall:
+			fmt.Fprintf(sb, " %v", blk.calls[in.a].expr)
+		}
+		sb.WriteByte('\n')
+	}
+	for i, p := range blk.protos {
+		fmt.Fprintf(sb, "%sLAMBDA %d %s\n", indent, i, p.name)
+		p.body.disassemble(sb, indent+"  ")
+	}
+}
+
+// notCompilableError is returned, if a value cannot be compiled.
+type notCompilableError struct {
+	val    Value
+	reason string
+}
+
+func (e *notCompilableError) Error() string {
+	return fmt.Sprintf("cannot compile %v: %s", e.val, e.reason)
+}
+
+// scope is the lexical scope of compiled code. The values of its local
+// variables are stored in the slots of a frame.
+type scope struct {
+	parent  *scope
+	syms    []*Symbol        // symbol of every slot
+	defined []bool           // slot is bound by DEFINE, it might be unbound
+	cache   map[Value]*block // values compiled for a vmEnv
+}
+
+// slot returns the slot of the given symbol, which is created if needed.
+func (sc *scope) slot(sym *Symbol, defined bool) int {
+	key := sym.key()
+	for i, s := range sc.syms {
+		if s == key {
+			return i
+		}
+	}
+	sc.syms = append(sc.syms, key)
+	sc.defined = append(sc.defined, defined)
+	return len(sc.syms) - 1
+}
+
+// resolve returns the scope that binds the given symbol, together with its
+// depth and its slot.
+func (sc *scope) resolve(sym *Symbol) (owner *scope, depth, slot int, found bool) {
+	key := sym.key()
+	for cur := sc; cur != nil; cur = cur.parent {
+		for i, s := range cur.syms {
+			if s == key {
+				return cur, depth, i, true
+			}
+		}
+		depth++
+	}
+	return nil, 0, 0, false
+}
+
+// maxCached is the maximum number of values compiled for a vmEnv per scope.
+const maxCached = 1024
+
+// compiled returns the block of the given value, compiled in this scope.
+func (sc *scope) compiled(e *Engine, val Value) (*block, error) {
+	if blk, found := sc.cache[val]; found {
+		return blk, nil
+	}
+	c := newCompiler(e, sc)
+	c.collectAssigned(val)
+	if err := c.compile(val, true); err != nil {
+		return nil, err
+	}
+	switch val.(type) {
+	case *Symbol, *String, *Pair, *Vector:
+		if sc.cache == nil {
+			sc.cache = map[Value]*block{}
+		}
+		if len(sc.cache) < maxCached {
+			sc.cache[val] = c.blk
+		}
+	}
+	return c.blk, nil
+}
+
+// compileFn compiles a call of a special form.
+type compileFn func(c *compiler, expr Value, b *Builtin, args []Value, tail bool) error
+
+var (
+	// compiledForms are the core forms that are translated into instructions.
+	compiledForms = map[*Builtin]compileFn{}
+
+	// engineForms are the core forms that need an Engine as environment.
+	// They cannot be compiled within a lexical scope.
+	engineForms = map[*Builtin]bool{}
+
+	quoteForm, defineForm, setForm, beginForm *Builtin
+)
+
+func init() {
+	fns := map[string]compileFn{
+		"QUOTE":  (*compiler).compileQuote,
+		"IF":     (*compiler).compileIf,
+		"COND":   (*compiler).compileCond,
+		"BEGIN":  (*compiler).compileBegin,
+		"DEFINE": (*compiler).compileDefine,
+		"SET!":   (*compiler).compileSet,
+		"LAMBDA": (*compiler).compileLambda,
+		"LET":    (*compiler).compileLet,
+	}
+	for _, b := range coreForms {
+		if fn, found := fns[b.name]; found {
+			compiledForms[b] = fn
+		}
+		switch b.name {
+		case "HANDLER-CASE", "DEFMACRO", "DEFINE-SYNTAX", "SYNTAX-RULES":
+			engineForms[b] = true
+		case "QUOTE":
+			quoteForm = b
+		case "DEFINE":
+			defineForm = b
+		case "SET!":
+			setForm = b
+		case "BEGIN":
+			beginForm = b
+		}
+	}
+}
+
+// compiler translates values into the instructions of a block.
+type compiler struct {
+	e        *Engine
+	scope    *scope
+	blk      *block
+	sp       int              // current stack size
+	assigned map[*Symbol]bool // symbols bound by DEFINE or SET! in the value
+}
+
+func newCompiler(e *Engine, sc *scope) *compiler {
+	return &compiler{e: e, scope: sc, blk: &block{}}
+}
+
+// emit appends an instruction, that changes the stack size by delta, and
+// returns its position.
+func (c *compiler) emit(op opcode, a, b int, delta int) int {
+	c.blk.code = append(c.blk.code, instr{op: op, a: int32(a), b: int32(b)})
+	c.sp += delta
+	if c.sp > c.blk.maxStack {
+		c.blk.maxStack = c.sp
+	}
+	return len(c.blk.code) - 1
+}
+
+func (c *compiler) pc() int { return len(c.blk.code) }
+
+// patch sets the jump target of the instruction at position pos to the
+// current position.
+func (c *compiler) patch(pos int) {
+	in := &c.blk.code[pos]
+	switch in.op {
+	case opLocalDef, opSetLocalDef, opGuard:
+		in.c = int32(c.pc())
+	default:
+		in.a = int32(c.pc())
+	}
+}
+
+func (c *compiler) constant(val Value) int {
+	c.blk.consts = append(c.blk.consts, val)
+	return len(c.blk.consts) - 1
+}
+
+// cell returns the index of a new binding cell.
+func (c *compiler) cell() int {
+	c.blk.cells = append(c.blk.cells, bindingCell{})
+	return len(c.blk.cells) - 1
+}
+
+func (c *compiler) pushConst(val Value) { c.emit(opConst, c.constant(val), 0, 1) }
+
+// region records that the instructions from start to the current position
+// evaluate arguments of the given call.
+func (c *compiler) region(start int, expr Value, form Form, args []Value, headPos int) {
+	if start < c.pc() {
+		c.blk.regions = append(c.blk.regions, region{
+			start: start, end: c.pc(), expr: expr, form: form, args: args, headPos: headPos})
+	}
+}
+
+// collectAssigned records the symbols, that are bound by DEFINE or SET!
+// within the given value.
+func (c *compiler) collectAssigned(val Value) {
+	var vals []Value
+	switch v := val.(type) {
+	case *Pair:
+		vals, _ = v.getElems()
+	case *Vector:
+		vals = v.GetSlice()
+	default:
+		return
+	}
+	if len(vals) > 1 {
+		if head, isSymbol := vals[0].(*Symbol); isSymbol {
+			if form, found := c.e.symMap.Lookup(head); found && (form == defineForm || form == setForm) {
+				target := vals[1]
+				if p, isPair := target.(*Pair); isPair && p != nil {
+					target = p.GetFirst()
+				}
+				if sym, isSymbol := target.(*Symbol); isSymbol {
+					if c.assigned == nil {
+						c.assigned = map[*Symbol]bool{}
+					}
+					c.assigned[sym.key()] = true
+				}
+			}
+		}
+	}
+	for _, v := range vals {
+		c.collectAssigned(v)
+	}
+}
+
+// assumption states, that a global symbol is bound to a builtin.
+type assumption struct {
+	sym *Symbol
+	b   *Builtin
+}
+
+// guard emits the instructions, that check the assumptions under which the
+// given value is compiled. If one does not hold, the value is evaluated
+// without them. The returned instructions must be patched to jump to the end
+// of the code of the value.
+func (c *compiler) guard(val Value, as []assumption, tail bool) []int {
+	var guards []int
+	for _, a := range as {
+		c.blk.guards = append(c.blk.guards, &guardSite{sym: a.sym, want: a.b, expr: val, scope: c.scope, tail: tail})
+		guards = append(guards, c.emit(opGuard, len(c.blk.guards)-1, 0, 0))
+	}
+	return guards
+}
+
+// compile the given value. The resulting code pushes its value onto the
+// stack. In tail position, it may return a tail call instead.
+func (c *compiler) compile(val Value, tail bool) error {
+	if cv, as, isConst := c.constValue(val); isConst {
+		guards := c.guard(val, as, tail)
+		c.pushConst(cv)
+		for _, pos := range guards {
+			c.patch(pos)
+		}
+		return nil
+	}
+	switch v := val.(type) {
+	case *Symbol:
+		c.load(v, c.scope, 0)
+		return nil
+	case *Pair:
+		elems, rest := v.getElems()
+		if rest != nil {
+			return &notCompilableError{val, "dotted list"}
+		}
+		return c.compileCall(val, elems, tail)
+	case *Vector:
+		return c.compileCall(val, v.GetSlice(), tail)
+	}
+	c.pushConst(val)
+	return nil
+}
+
+// constValue returns the value of the given value, if it is a constant,
+// together with the assumptions under which it is constant.
+func (c *compiler) constValue(val Value) (Value, []assumption, bool) {
+	var vals []Value
+	switch v := val.(type) {
+	case *Symbol:
+		return nil, nil, false
+	case *Pair:
+		if v == nil {
+			return v, nil, true
+		}
+		elems, rest := v.getElems()
+		if rest != nil {
+			return nil, nil, false
+		}
+		vals = elems
+	case *Vector:
+		if vals = v.GetSlice(); len(vals) == 0 {
+			return v, nil, true
+		}
+	default:
+		return val, nil, true
+	}
+	b := c.globalBuiltin(vals[0])
+	if b == nil {
+		return nil, nil, false
+	}
+	var as []assumption
+	if sym, isSymbol := vals[0].(*Symbol); isSymbol {
+		as = []assumption{{sym, b}}
+	}
+	if b == quoteForm {
+		if len(vals) == 2 {
+			return vals[1], as, true
+		}
+		return nil, nil, false
+	}
+	return c.fold(b, vals[1:], as)
+}
+
+// fold calls a pure builtin, if all arguments are constant.
+func (c *compiler) fold(b *Builtin, args []Value, as []assumption) (Value, []assumption, bool) {
+	if !b.IsPure() || b.IsSpecial() {
+		return nil, nil, false
+	}
+	vals := make([]Value, len(args))
+	for i, arg := range args {
+		val, argAs, isConst := c.constValue(arg)
+		if !isConst {
+			return nil, nil, false
+		}
+		vals[i], as = val, append(as, argAs...)
+	}
+	res, err := b.Call(c.e, vals)
+	if err != nil {
+		return nil, nil, false
+	}
+	if _, isTailCall := res.(*tailCall); isTailCall {
+		return nil, nil, false
+	}
+	return res, as, true
+}
+
+// globalBuiltin returns the builtin the head of a call denotes at compile
+// time, if it is not bound in a lexical scope, and not bound by DEFINE or
+// SET! within the compiled value.
+func (c *compiler) globalBuiltin(head Value) *Builtin {
+	switch h := head.(type) {
+	case *Symbol:
+		if _, _, _, found := c.scope.resolve(h); found || c.assigned[h.key()] {
+			return nil
+		}
+		if val, found := c.e.symMap.Lookup(h); found {
+			b, _ := val.(*Builtin)
+			return b
+		}
+	case *Builtin:
+		return h
+	}
+	return nil
+}
+
+// load pushes the value of the given symbol, which is resolved in the given
+// scope. A slot bound by DEFINE might be unbound; then the symbol is
+// resolved in the parent scope.
+func (c *compiler) load(sym *Symbol, sc *scope, base int) {
+	owner, depth, slot, found := sc.resolve(sym)
+	if !found {
+		c.emit(opGlobal, c.constant(sym), c.cell(), 1)
+		return
+	}
+	if !owner.defined[slot] {
+		c.emit(opLocal, base+depth, slot, 1)
+		return
+	}
+	pos := c.emit(opLocalDef, base+depth, slot, 0)
+	c.load(sym, owner.parent, base+depth+1)
+	c.patch(pos)
+}
+
+// store sets the value of the given symbol to the top value of the stack.
+func (c *compiler) store(sym *Symbol, sc *scope, base int) {
+	owner, depth, slot, found := sc.resolve(sym)
+	if !found {
+		c.emit(opSetGlobal, c.constant(sym), c.cell(), 0)
+		return
+	}
+	if !owner.defined[slot] {
+		c.emit(opSetLocal, base+depth, slot, 0)
+		return
+	}
+	pos := c.emit(opSetLocalDef, base+depth, slot, 0)
+	c.store(sym, owner.parent, base+depth+1)
+	c.patch(pos)
+}
+
+// compileCall compiles a list or a vector, that is evaluated as a form call.
+func (c *compiler) compileCall(expr Value, vals []Value, tail bool) error {
+	if !isCallHead(vals[0]) {
+		c.emit(opNotCall, c.constant(expr), 0, 1)
+		return nil
+	}
+	args := vals[1:]
+	if b := c.globalBuiltin(vals[0]); b != nil {
+		if fn, found := compiledForms[b]; found {
+			if !b.acceptsArity(len(args)) {
+				return &notCompilableError{expr, "wrong number of arguments"}
+			}
+			var guards []int
+			if sym, isSymbol := vals[0].(*Symbol); isSymbol {
+				guards = c.guard(expr, []assumption{{sym, b}}, tail)
+			}
+			if err := fn(c, expr, b, args, tail); err != nil {
+				return err
+			}
+			for _, pos := range guards {
+				c.patch(pos)
+			}
+			return nil
+		}
+		if c.scope != nil && engineForms[b] {
+			return &notCompilableError{expr, b.name + " within a lexical scope"}
+		}
+	}
+
+	cs := &callSite{expr: expr, head: vals[0], args: args, scope: c.scope, tail: tail}
+	c.blk.calls = append(c.blk.calls, cs)
+	idx := len(c.blk.calls) - 1
+	switch head := vals[0].(type) {
+	case *Symbol:
+		if _, _, _, found := c.scope.resolve(head); found {
+			c.load(head, c.scope, 0)
+			c.emit(opCheckHead, idx, 0, 0)
+		} else {
+			c.emit(opHead, idx, 0, 1)
+		}
+	case Form:
+		c.pushConst(head)
+		c.emit(opCheckHead, idx, 0, 0)
+	default:
+		start := c.pc()
+		if err := c.compile(head, false); err != nil {
+			return err
+		}
+		c.region(start, expr, nil, nil, -1)
+		c.emit(opCheckHead, idx, 0, 0)
+	}
+	headPos := c.sp - 1
+	start := c.pc()
+	for _, arg := range args {
+		if err := c.compile(arg, false); err != nil {
+			return err
+		}
+	}
+	c.region(start, expr, nil, nil, headPos)
+	if tail {
+		c.emit(opTailCall, idx, 0, -len(args))
+	} else {
+		c.emit(opCall, idx, 0, -len(args))
+	}
+	cs.end = c.pc()
+	return nil
+}
+
+// body compiles a sequence of values. The value of the last one is the
+// result. If a value, except the last one, fails, the call of the given
+// form is recorded.
+func (c *compiler) body(vals []Value, tail bool, expr Value, b *Builtin, args []Value) error {
+	if len(vals) == 0 {
+		c.pushConst(Nil())
+		return nil
+	}
+	last := len(vals) - 1
+	for _, val := range vals[:last] {
+		start := c.pc()
+		if err := c.compile(val, false); err != nil {
+			return err
+		}
+		if b != nil {
+			c.region(start, expr, b, args, -1)
+		}
+		c.emit(opPop, 0, 0, -1)
+	}
+	return c.compile(vals[last], tail)
+}
+
+// declare creates the slots of all symbols, that are bound by DEFINE in the
+// given body.
+func (c *compiler) declare(body []Value) {
+	for _, val := range body {
+		var vals []Value
+		switch v := val.(type) {
+		case *Pair:
+			vals, _ = v.getElems()
+		case *Vector:
+			vals = v.GetSlice()
+		}
+		if len(vals) < 2 {
+			continue
+		}
+		switch c.globalBuiltin(vals[0]) {
+		case defineForm:
+			if p, isPair := vals[1].(*Pair); isPair && p != nil {
+				if sym, isSymbol := p.GetFirst().(*Symbol); isSymbol {
+					c.scope.slot(sym, true)
+				}
+			} else if sym, isSymbol := vals[1].(*Symbol); isSymbol {
+				c.scope.slot(sym, true)
+			}
+		case beginForm:
+			c.declare(vals[1:])
+		}
+	}
+}
+
+func (c *compiler) compileQuote(_ Value, _ *Builtin, args []Value, _ bool) error {
+	c.pushConst(args[0])
+	return nil
+}
+
+func (c *compiler) compileIf(expr Value, b *Builtin, args []Value, tail bool) error {
+	if test, as, isConst := c.constValue(args[0]); isConst {
+		guards := c.guard(expr, as, tail)
+		var err error
+		if IsTrue(test) {
+			err = c.compile(args[1], tail)
+		} else if len(args) > 2 {
+			err = c.compile(args[2], tail)
+		} else {
+			c.pushConst(Nil())
+		}
+		for _, pos := range guards {
+			c.patch(pos)
+		}
+		return err
+	}
+	start := c.pc()
+	if err := c.compile(args[0], false); err != nil {
+		return err
+	}
+	c.region(start, expr, b, args, -1)
+	jumpElse := c.emit(opJumpFalse, 0, 0, -1)
+	if err := c.compile(args[1], tail); err != nil {
+		return err
+	}
+	jumpEnd := c.emit(opJump, 0, 0, -1)
+	c.patch(jumpElse)
+	if len(args) > 2 {
+		if err := c.compile(args[2], tail); err != nil {
+			return err
+		}
+	} else {
+		c.pushConst(Nil())
+	}
+	c.patch(jumpEnd)
+	return nil
+}
+
+func (c *compiler) compileCond(expr Value, b *Builtin, args []Value, tail bool) error {
+	var jumpsEnd []int
+	base := c.sp
+	done, tested := false, false
+	for _, arg := range args {
+		clause, isSeq := arg.(Sequence)
+		if !isSeq {
+			return &notCompilableError{expr, "COND clause is not a sequence"}
+		}
+		vals := clause.GetSlice()
+		if len(vals) == 0 {
+			return &notCompilableError{expr, "empty COND clause"}
+		}
+		test, isConst := vals[0], isElse(c.e, vals[0])
+		if !isConst {
+			var as []assumption
+			// Assumptions are checked before any test is evaluated.
+			if test, as, isConst = c.constValue(vals[0]); isConst && len(as) > 0 {
+				if isConst = !tested; isConst {
+					jumpsEnd = append(jumpsEnd, c.guard(expr, as, tail)...)
+				}
+			}
+		}
+		if isConst {
+			if !IsTrue(test) {
+				continue
+			}
+			if len(vals) == 1 {
+				c.pushConst(test)
+			} else if err := c.body(vals[1:], tail, expr, b, args); err != nil {
+				return err
+			}
+			done = true
+			break
+		}
+		tested = true
+		start := c.pc()
+		if err := c.compile(vals[0], false); err != nil {
+			return err
+		}
+		c.region(start, expr, b, args, -1)
+		if len(vals) == 1 {
+			jumpsEnd = append(jumpsEnd, c.emit(opJumpTrue, 0, 0, -1))
+			continue
+		}
+		jumpNext := c.emit(opJumpFalse, 0, 0, -1)
+		if err := c.body(vals[1:], tail, expr, b, args); err != nil {
+			return err
+		}
+		jumpsEnd = append(jumpsEnd, c.emit(opJump, 0, 0, 0))
+		c.patch(jumpNext)
+		c.sp = base
+	}
+	if !done {
+		c.pushConst(Nil())
+	}
+	for _, pos := range jumpsEnd {
+		c.patch(pos)
+	}
+	c.sp = base + 1
+	return nil
+}
+
+func (c *compiler) compileBegin(expr Value, b *Builtin, args []Value, tail bool) error {
+	return c.body(args, tail, expr, b, args)
+}
+
+func (c *compiler) compileDefine(expr Value, b *Builtin, args []Value, _ bool) error {
+	var sym *Symbol
+	if p, isPair := args[0].(*Pair); isPair && p != nil {
+		s, isSymbol := p.GetFirst().(*Symbol)
+		if !isSymbol {
+			return &notCompilableError{expr, "DEFINE name is not a symbol"}
+		}
+		if err := c.compileClosure(s.GetValue(), p.GetSecond(), args[1:]); err != nil {
+			return err
+		}
+		sym = s
+	} else {
+		s, isSymbol := args[0].(*Symbol)
+		if !isSymbol || len(args) != 2 {
+			return &notCompilableError{expr, "wrong DEFINE"}
+		}
+		start := c.pc()
+		if err := c.compile(args[1], false); err != nil {
+			return err
+		}
+		c.region(start, expr, b, args, -1)
+		sym = s
+	}
+	if c.scope == nil {
+		c.emit(opDefGlobal, c.constant(sym), 0, 0)
+		return nil
+	}
+	if _, depth, slot, found := c.scope.resolve(sym); found && depth == 0 {
+		c.emit(opDefLocal, slot, c.constant(sym), 0)
+		return nil
+	}
+	return &notCompilableError{expr, "DEFINE not at the top of a body"}
+}
+
+func (c *compiler) compileSet(expr Value, b *Builtin, args []Value, _ bool) error {
+	sym, isSymbol := args[0].(*Symbol)
+	if !isSymbol {
+		return &notCompilableError{expr, "SET! of a non-symbol"}
+	}
+	start := c.pc()
+	if _, _, _, found := c.scope.resolve(sym); !found {
+		c.emit(opCheckBound, c.constant(sym), c.cell(), 0)
+	}
+	if err := c.compile(args[1], false); err != nil {
+		return err
+	}
+	c.store(sym, c.scope, 0)
+	c.region(start, expr, b, args, -1)
+	return nil
+}
+
+func (c *compiler) compileLambda(_ Value, _ *Builtin, args []Value, _ bool) error {
+	return c.compileClosure("", args[0], args[1:])
+}
+
+// compileClosure compiles the body of a LAMBDA expression in a new scope
+// and pushes a new closure.
+func (c *compiler) compileClosure(name string, spec Value, body []Value) error {
+	params, rest, err := parseParams(spec)
+	if err != nil {
+		return &notCompilableError{spec, err.Error()}
+	}
+	sc := &scope{parent: c.scope}
+	for _, sym := range params {
+		if owner, _, _, found := sc.resolve(sym); found && owner == sc {
+			return &notCompilableError{spec, "duplicate parameter"}
+		}
+		sc.slot(sym, false)
+	}
+	if rest != nil {
+		if owner, _, _, found := sc.resolve(rest); found && owner == sc {
+			return &notCompilableError{spec, "duplicate parameter"}
+		}
+		sc.slot(rest, false)
+	}
+	p := &proto{name: name, params: params, rest: rest}
+	if len(body) > 1 {
+		if doc, isString := body[0].(*String); isString {
+			p.doc, body = doc.GetValue(), body[1:]
+		}
+	}
+	sub := newCompiler(c.e, sc)
+	sub.assigned = c.assigned
+	sub.declare(body)
+	if err = sub.body(body, true, nil, nil, nil); err != nil {
+		return err
+	}
+	p.body, p.size = sub.blk, len(sc.syms)
+	c.blk.protos = append(c.blk.protos, p)
+	c.emit(opClosure, len(c.blk.protos)-1, 0, 1)
+	return nil
+}
+
+func (c *compiler) compileLet(expr Value, b *Builtin, args []Value, tail bool) error {
+	bindings, isSeq := args[0].(Sequence)
+	if !isSeq {
+		return &notCompilableError{expr, "LET bindings are not a sequence"}
+	}
+	bs := bindings.GetSlice()
+	start := c.pc()
+	c.emit(opAlloc, len(bs)+1, 0, 0)
+	sc := &scope{parent: c.scope}
+	ls := &letSite{}
+	for _, binding := range bs {
+		if sym, isSymbol := binding.(*Symbol); isSymbol {
+			c.pushConst(Nil())
+			ls.slots = append(ls.slots, sc.slot(sym, false))
+			continue
+		}
+		seq, isSeq := binding.(Sequence)
+		if !isSeq {
+			return &notCompilableError{expr, "LET binding is not a sequence"}
+		}
+		vals := seq.GetSlice()
+		if len(vals) != 2 {
+			return &notCompilableError{expr, "wrong LET binding"}
+		}
+		sym, isSymbol := vals[0].(*Symbol)
+		if !isSymbol {
+			return &notCompilableError{expr, "wrong LET binding"}
+		}
+		if err := c.compile(vals[1], false); err != nil {
+			return err
+		}
+		ls.slots = append(ls.slots, sc.slot(sym, false))
+	}
+	c.region(start, expr, b, args, -1)
+
+	outer := c.scope
+	c.scope = sc
+	defer func() { c.scope = outer }()
+	c.declare(args[1:])
+	ls.size = len(sc.syms)
+	c.blk.lets = append(c.blk.lets, ls)
+	c.emit(opEnter, len(c.blk.lets)-1, 0, -len(bs))
+	if err := c.body(args[1:], tail, expr, b, args); err != nil {
+		return err
+	}
+	if !tail {
+		c.emit(opLeave, 0, 0, 0)
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/t73fde/sxpf"
+	"github.com/t73fde/sxpf/builtins"
+)
+
+// runCompiled parses the source, compiles it, and runs the code.
+func runCompiled(engine *sxpf.Engine, src string) (sxpf.Value, error) {
+	expr, err := sxpf.ParseString(engine, src)
+	if err != nil {
+		return nil, err
+	}
+	code, err := engine.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return code.Run()
+}
+
+func newCompileTestEngine() *sxpf.Engine {
+	engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+	builtins.Register(engine)
+	return engine
+}
+
+func TestCompile(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(BEGIN (DEFINE (f) (g) (DEFINE (g) 2) (g)) (DEFINE (g) 1) (f))", "2"},
+		{"(BEGIN (DEFINE (f) (LIST (g) (BEGIN (DEFINE (g) 2) (g)))) (DEFINE (g) 1) (f))", "(1 2)"},
+		{"(BEGIN (DEFINE x 1) (DEFINE (f) (SET! x 2) (DEFINE x 3) (SET! x 4) x) (LIST (f) x))", "(4 2)"},
+		{"(LET ((x 1) (x 2)) x)", "2"},
+		{"(LET ((x 1)) (LET ((y 2)) (LET ((z 3)) (LIST x y z))))", "(1 2 3)"},
+		{"(LET ((x 1)) (AND x (LET ((y 2)) (OR () (+ x y)))))", "3"},
+		{"(LET ((x 1)) (UNWIND-PROTECT (SET! x 2) (SET! x (+ x 1))) x)", "3"},
+		{"(LET ((f (LAMBDA (x) (* x x)))) (APPLY f (LIST 3)))", "9"},
+		{"(BEGIN (DEFINE (f x) \"Square\" (* x x)) (LIST (f 3) (DOC f)))", `(9 "Square")`},
+		{"(LET ((x 1)) (HANDLER-CASE (RAISE (QUOTE e)) (e () x)))", "1"},
+		{"(BEGIN (DEFMACRO m (x) x) (m 3))", "3"},
+		{"((LAMBDA (n) (COND ((< n 0) -1) ((= n 0)) (ELSE 1))) 0)", "#t"},
+		{"(BEGIN (DEFINE (loop n acc) (IF (= n 0) acc (loop (- n 1) (+ acc 1)))) (loop 100000 0))", "100000"},
+		{"(BEGIN (DEFINE CAR CDR) (CAR (QUOTE (1 2))))", "(2)"},
+		{"(BEGIN (DEFINE (g) (+ 1 2)) (DEFINE + -) (g))", "-1"},
+		{"(BEGIN (DEFINE (f x) (IF x 1 2)) (SET! IF (LAMBDA (a b c) c)) (f #t))", "2"},
+		{"(BEGIN (DEFINE (f x) (AND x (+ 1 2))) (DEFINE r1 (f 1)) (SET! + -) (LIST r1 (f 1)))", "(3 -1)"},
+	}
+	for i, tc := range testcases {
+		val, err := runCompiled(newCompileTestEngine(), tc.src)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should evaluate to %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+
+		engine := newCompileTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if val, err = engine.Eval(expr); err != nil || val.String() != tc.exp {
+			t.Errorf("%d: interpreted %v should evaluate to %v, but got: %v / %v", i, tc.src, tc.exp, val, err)
+		}
+	}
+}
+
+func TestCompileFold(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(+ 1 (* 2 3))", "0000 GUARD + 0003\n0001 GUARD * 0003\n0002 CONST 7\n"},
+		{"(IF (< 1 2) (QUOTE a) (f))", "0000 GUARD IF 0004\n0001 GUARD < 0004\n0002 GUARD QUOTE 0004\n0003 CONST A\n"},
+		{"(COND ((= 1 2) (f)) ((STRING-LENGTH \"ab\")))",
+			"0000 GUARD COND 0004\n0001 GUARD = 0004\n0002 GUARD STRING-LENGTH 0004\n0003 CONST 2\n"},
+		{"(/ 1 0)", "0000 HEAD (/ 1 0)\n0001 CONST 1\n0002 CONST 0\n0003 TAIL-CALL (/ 1 0)\n"},
+		{"(LAMBDA (x) (+ x (- 3 1)))",
+			"0000 GUARD LAMBDA 0002\n0001 CLOSURE 0\nLAMBDA 0 \n  0000 HEAD (+ X (- 3 1))\n  0001 LOCAL 0 0\n  0002 GUARD - 0004\n  0003 CONST 2\n  0004 TAIL-CALL (+ X (- 3 1))\n"},
+		{"(BEGIN (DEFINE + -) (+ 1 2))",
+			"0000 GUARD BEGIN 0009\n0001 GUARD DEFINE 0004\n0002 GLOBAL -\n0003 DEF-GLOBAL +\n0004 POP\n0005 HEAD (+ 1 2)\n0006 CONST 1\n0007 CONST 2\n0008 TAIL-CALL (+ 1 2)\n"},
+	}
+	for i, tc := range testcases {
+		engine := newCompileTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		code, err := engine.Compile(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := code.String(); got != tc.exp {
+			t.Errorf("%d: %v should compile to\n%v\nbut got\n%v", i, tc.src, tc.exp, got)
+		}
+	}
+}
+
+func TestCompileFreshValues(t *testing.T) {
+	engine := newCompileTestEngine()
+	for _, src := range []string{"(CONS 1 2)", "(LIST 1 2)", "(STRING->LIST \"ab\")", "(VECTOR->LIST (VECTOR 1))"} {
+		expr, err := sxpf.ParseString(engine, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := engine.Compile(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val1, err1 := code.Run()
+		val2, err2 := code.Run()
+		if err1 != nil || err2 != nil {
+			t.Errorf("%v resulted in error: %v / %v", src, err1, err2)
+			continue
+		}
+		if val1 == val2 {
+			t.Errorf("%v should create a new value in every run, but got %v twice", src, val1)
+		}
+	}
+}
+
+func TestCompileRedefined(t *testing.T) {
+	testcases := []struct {
+		def, redef, src string
+		exp             string
+	}{
+		{"(DEFINE (g) (+ 1 2))", "(DEFINE + -)", "(g)", "-1"},
+		{"(DEFINE (g x) (IF x 1 2))", "(DEFINE (IF a b c) c)", "(g #t)", "2"},
+		{"(DEFINE (g) (LET ((x (CAR (QUOTE (1 2))))) x))", "(DEFINE CAR CDR)", "(g)", "(2)"},
+		{"(DEFINE (g) (COND ((< 2 1) 1) (ELSE 2)))", "(DEFINE < >)", "(g)", "1"},
+	}
+	for i, tc := range testcases {
+		engine := newCompileTestEngine()
+		for _, src := range []string{tc.def, tc.redef} {
+			if _, err := runCompiled(engine, src); err != nil {
+				t.Fatal(err)
+			}
+		}
+		val, err := runCompiled(engine, tc.src)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: after %v, %v should evaluate to %v, but got: %v", i, tc.redef, tc.src, tc.exp, got)
+		}
+	}
+}
+
+func TestCompileFallback(t *testing.T) {
+	engine := newCompileTestEngine()
+	for _, src := range []string{"(+ 1 2)", "(LAMBDA (x) (DEFMACRO m () x))", "(LAMBDA (x) (IF x (DEFINE y 1)))"} {
+		expr, err := sxpf.ParseString(engine, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := engine.Compile(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, exp := code.IsCompiled(), !strings.Contains(src, "LAMBDA"); got != exp {
+			t.Errorf("%v: IsCompiled should be %v, but got %v", src, exp, got)
+		}
+	}
+}
+
+func TestCompileGlobals(t *testing.T) {
+	root := newCompileTestEngine()
+	mid := root.NewChild()
+	inner := mid.NewChild()
+	sym := root.MakeSymbol("X")
+	root.Define(sym, sxpf.NewInteger(1))
+	code, err := inner.Compile(sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		change func()
+		exp    string
+	}{
+		{func() {}, "1"},
+		{func() { mid.Define(sym, sxpf.NewInteger(2)) }, "2"},
+		{func() { inner.Define(sym, sxpf.NewInteger(3)) }, "3"},
+		{func() { inner.SymbolMap().Delete(sym) }, "2"},
+		{func() { mid.SymbolMap().Update(sym, sxpf.NewInteger(4)) }, "4"},
+		{func() { mid.SymbolMap().Delete(sym) }, "1"},
+	}
+	for i, tc := range testcases {
+		tc.change()
+		val, err := code.Run()
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: expected %v, but got %v", i, tc.exp, got)
+		}
+	}
+	other := newCompileTestEngine()
+	other.Define(sym, sxpf.NewInteger(5))
+	other.SymbolMap().Delete(sym)
+	if val, err := code.Run(); err != nil || val.String() != "1" {
+		t.Errorf("changes of another engine must not change the binding, but got %v / %v", val, err)
+	}
+	root.SymbolMap().Delete(sym)
+	if _, err = code.Run(); err == nil {
+		t.Error("error expected for unbound symbol")
+	}
+}
+
+func TestCompileGlobalsForeign(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	def, use := sxpf.NewEngine(smk), sxpf.NewEngine(smk)
+	builtins.Register(def)
+	builtins.Register(use)
+	k, getk := smk.MakeSymbol("k"), smk.MakeSymbol("getk")
+	def.Define(k, sxpf.NewInteger(1))
+	inner := def.NewChild()
+	if _, err := runCompiled(inner, "(DEFINE-SYNTAX getk (SYNTAX-RULES () ((_) k)))"); err != nil {
+		t.Fatal(err)
+	}
+	macro, _ := inner.SymbolMap().Lookup(getk)
+	use.Define(getk, macro)
+	expr, err := sxpf.ParseString(use, "(getk)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := use.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		change func()
+		exp    string
+	}{
+		{func() {}, "1"},
+		{func() { inner.Define(k, sxpf.NewInteger(2)) }, "2"},
+		{func() { inner.SymbolMap().Delete(k) }, "1"},
+	}
+	for i, tc := range testcases {
+		tc.change()
+		val, err := code.Run()
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: expected %v, but got %v", i, tc.exp, got)
+		}
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	engine := newMacroTestEngine()
+	engine.SetMaxSteps(1000)
+	_, err := runCompiled(engine, srcLoop)
+	var mse *sxpf.MaxStepsError
+	if !errors.As(err, &mse) {
+		t.Errorf("MaxStepsError expected, but got %v", err)
+	}
+
+	engine = newMacroTestEngine()
+	engine.SetMaxAlloc(500)
+	_, err = runCompiled(engine, srcLoop)
+	var mae *sxpf.MaxAllocError
+	if !errors.As(err, &mae) {
+		t.Errorf("MaxAllocError expected, but got %v", err)
+	}
+
+	engine = newMacroTestEngine()
+	expr, err := sxpf.ParseString(engine, srcLoop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := engine.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = code.RunContext(ctx)
+	var ce *sxpf.CanceledError
+	if !errors.As(err, &ce) {
+		t.Errorf("CanceledError expected, but got %v", err)
+	}
+}
+
+const srcFib = "(DEFINE (fib n) (IF (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))"
+
+// newBenchEngine defines the function fib, either interpreted or compiled,
+// and returns an expression that calls it.
+func newBenchEngine(b *testing.B, compiled bool) (*sxpf.Engine, sxpf.Value) {
+	engine := newCompileTestEngine()
+	def, err := sxpf.ParseString(engine, srcFib)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if compiled {
+		_, err = runCompiled(engine, srcFib)
+	} else {
+		_, err = engine.Eval(def)
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+	expr, err := sxpf.ParseString(engine, "(LET ((n 15)) (fib n))")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return engine, expr
+}
+
+func BenchmarkEval(b *testing.B) {
+	engine, expr := newBenchEngine(b, false)
+	for i := 0; i < b.N; i++ {
+		if _, err := engine.Eval(expr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	engine, expr := newBenchEngine(b, true)
+	code, err := engine.Compile(expr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = code.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
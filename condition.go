@@ -0,0 +1,232 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorValue is an error that is also a value. It is created by the RAISE
+// form, or by a builtin that wants to signal a specific error condition. Any
+// other Go error is converted into an ErrorValue, when it is handled by the
+// HANDLER-CASE form.
+type ErrorValue struct {
+	tag  *Symbol
+	msg  string
+	data Value
+	err  error // the Go error, that was converted into this value
+}
+
+// NewErrorValue creates a new error value. The tag classifies the error, the
+// data value may give more details. If the tag is nil, an uninterned symbol
+// ERROR is used.
+func NewErrorValue(tag *Symbol, msg string, data Value) *ErrorValue {
+	if tag == nil {
+		tag = NewUninternedSymbol("ERROR")
+	}
+	if data == nil {
+		data = Nil()
+	}
+	return &ErrorValue{tag: tag, msg: msg, data: data}
+}
+
+// Tag returns the symbol that classifies the error.
+func (ev *ErrorValue) Tag() *Symbol { return ev.tag }
+
+// Message returns the error message.
+func (ev *ErrorValue) Message() string { return ev.msg }
+
+// Data returns the value that describes the error in more detail.
+func (ev *ErrorValue) Data() Value { return ev.data }
+
+func (ev *ErrorValue) Error() string {
+	if ev.msg == "" {
+		return ev.tag.GetValue()
+	}
+	return ev.tag.GetValue() + ": " + ev.msg
+}
+
+// Unwrap returns the Go error, that was converted into the error value.
+func (ev *ErrorValue) Unwrap() error { return ev.err }
+
+func (ev *ErrorValue) Equal(other Value) bool {
+	if ev == nil || other == nil {
+		return ev == other
+	}
+	if o, ok := other.(*ErrorValue); ok {
+		return ev.tag.Equal(o.tag) && ev.msg == o.msg && ev.data.Equal(o.data)
+	}
+	return false
+}
+
+func (ev *ErrorValue) String() string {
+	return "#<error " + ev.tag.String() + " " + NewString(ev.msg).String() + ">"
+}
+
+// isFatal returns true, if the error results from exceeding a limit of the
+// engine. Such errors cannot be handled by HANDLER-CASE.
+func isFatal(err error) bool {
+	var mde *MaxDepthError
+	var mse *MaxStepsError
+	var mae *MaxAllocError
+	var ce *CanceledError
+	return errors.As(err, &mde) || errors.As(err, &mse) || errors.As(err, &mae) || errors.As(err, &ce)
+}
+
+// asErrorValue returns the error value wrapped in the given error, or
+// converts the error into an error value with the tag ERROR.
+func (e *Engine) asErrorValue(err error) *ErrorValue {
+	var ev *ErrorValue
+	if errors.As(err, &ev) {
+		return ev
+	}
+	var ee *EvalError
+	if errors.As(err, &ee) {
+		err = ee.Err
+	}
+	return &ErrorValue{tag: e.MakeSymbol("ERROR"), msg: err.Error(), data: Nil(), err: err}
+}
+
+// (RAISE tag message? data?) raises an error value with the given tag,
+// message, and data. (RAISE error) raises the given error value again.
+func raiseFn(_ Environment, args []Value) (Value, error) {
+	if ev, ok := args[0].(*ErrorValue); ok && len(args) == 1 {
+		return nil, ev
+	}
+	tag, err := GetSymbol(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	msg := ""
+	if len(args) > 1 {
+		if msg, err = GetString(args, 1); err != nil {
+			return nil, err
+		}
+	}
+	var data Value
+	if len(args) > 2 {
+		data = args[2]
+	}
+	return nil, NewErrorValue(tag, msg, data)
+}
+
+// (HANDLER-CASE expr (tag (var?) body...)...) evaluates expr. If this results
+// in an error, the first clause with a matching tag is selected: the error
+// value is bound to var, and the body is evaluated. The tag may be a list
+// of tags. The tag ERROR matches all errors. If no clause matches, the error
+// is returned.
+func handlerCaseFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	res, err := Evaluate(e, args[0])
+	if err == nil || isFatal(err) {
+		return res, err
+	}
+	ev := e.asErrorValue(err)
+	for _, arg := range args[1:] {
+		clause, ok := arg.(Sequence)
+		if !ok {
+			return nil, fmt.Errorf("HANDLER-CASE clause %v is not a sequence", arg)
+		}
+		vals := clause.GetSlice()
+		if len(vals) < 2 {
+			return nil, fmt.Errorf("HANDLER-CASE clause %v needs a tag and a variable list", arg)
+		}
+		if !tagMatches(e, vals[0], ev.tag) {
+			continue
+		}
+		vars, ok := vals[1].(Sequence)
+		if !ok {
+			return nil, fmt.Errorf("HANDLER-CASE variable list %v is not a sequence", vals[1])
+		}
+		scope := e.newLocal()
+		if varList := vars.GetSlice(); len(varList) > 0 {
+			sym, err2 := GetSymbol(varList, 0)
+			if err2 != nil {
+				return nil, err2
+			}
+			scope.Define(sym, ev)
+		}
+		return evaluateBody(scope, vals[2:])
+	}
+	return nil, err
+}
+
+func tagMatches(e *Engine, clauseTag Value, tag *Symbol) bool {
+	switch ct := clauseTag.(type) {
+	case *Symbol:
+		return isNamedSymbol(e, ct, "ERROR") || ct.Equal(tag)
+	case Sequence:
+		for _, t := range ct.GetSlice() {
+			if tagMatches(e, t, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// (UNWIND-PROTECT expr cleanup...) evaluates expr and then all cleanup
+// expressions, even if expr resulted in an error. The result is the result
+// of expr. The cleanup expressions are not evaluated, if a limit of the
+// engine was exceeded.
+func unwindProtectFn(env Environment, args []Value) (Value, error) {
+	res, err := Evaluate(env, args[0])
+	if isFatal(err) {
+		return nil, err
+	}
+	for _, cleanup := range args[1:] {
+		if _, err2 := Evaluate(env, cleanup); err2 != nil {
+			return nil, err2
+		}
+	}
+	return res, err
+}
+
+// (ERROR-TAG error) returns the tag of the error value.
+func errorTagFn(_ Environment, args []Value) (Value, error) {
+	ev, err := getErrorValue(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ev.tag, nil
+}
+
+// (ERROR-MESSAGE error) returns the message of the error value.
+func errorMessageFn(_ Environment, args []Value) (Value, error) {
+	ev, err := getErrorValue(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return NewString(ev.msg), nil
+}
+
+// (ERROR-DATA error) returns the data of the error value.
+func errorDataFn(_ Environment, args []Value) (Value, error) {
+	ev, err := getErrorValue(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ev.data, nil
+}
+
+func getErrorValue(args []Value, idx int) (*ErrorValue, error) {
+	if idx < 0 || len(args) <= idx {
+		return nil, makeErrIndexOutOfBounds(args, idx)
+	}
+	if val, ok := args[idx].(*ErrorValue); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("%v / %d is not an error", args[idx], idx)
+}
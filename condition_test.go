@@ -0,0 +1,128 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestCondition(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(HANDLER-CASE (QUOTE a))", "A"},
+		{`(HANDLER-CASE (RAISE (QUOTE missing) "field" (QUOTE f)) (missing (e) e))`, `#<error MISSING "field">`},
+		{`(HANDLER-CASE (RAISE (QUOTE missing) "field" (QUOTE f)) (missing (e) (ERROR-DATA e)))`, "F"},
+		{`(HANDLER-CASE (RAISE (QUOTE missing) "field") (missing (e) (ERROR-MESSAGE e)))`, `"field"`},
+		{`(HANDLER-CASE (RAISE (QUOTE missing)) (other () (QUOTE o)) ((x missing) (e) (ERROR-TAG e)))`, "MISSING"},
+		{`(HANDLER-CASE (RAISE (QUOTE missing)) (other () (QUOTE o)) (ERROR () (QUOTE e)))`, "E"},
+		{"(HANDLER-CASE (CAT (unknown)) (ERROR (e) (ERROR-MESSAGE e)))", `"symbol \"UNKNOWN\" not found to form"`},
+		{"(HANDLER-CASE (HANDLER-CASE (RAISE (QUOTE a)) (b () (QUOTE b))) (a () (QUOTE a)))", "A"},
+		{"(HANDLER-CASE (HANDLER-CASE (RAISE (QUOTE a)) (a (e) (RAISE e))) (a () (QUOTE again)))", "AGAIN"},
+		{"(BEGIN (DEFINE x ()) (HANDLER-CASE (UNWIND-PROTECT (RAISE (QUOTE a)) (SET! x (QUOTE done))) (a () x)))", "DONE"},
+		{"(BEGIN (DEFINE x ()) (UNWIND-PROTECT (QUOTE r) (SET! x (QUOTE done))))", "R"},
+		{"(BEGIN (DEFINE x ()) (UNWIND-PROTECT (QUOTE r) (SET! x (QUOTE done))) x)", "DONE"},
+	}
+	for i, tc := range testcases {
+		engine := newMacroTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should evaluate to %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+		if val, err = runCompiled(newMacroTestEngine(), tc.src); err != nil || val.String() != tc.exp {
+			t.Errorf("%d: compiled %v should evaluate to %v, but got: %v / %v", i, tc.src, tc.exp, val, err)
+		}
+	}
+}
+
+func TestConditionUnhandled(t *testing.T) {
+	engine := newMacroTestEngine()
+	expr, err := sxpf.ParseString(engine, `(HANDLER-CASE (RAISE (QUOTE missing) "field") (other () (QUOTE o)))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = engine.Eval(expr)
+	var ev *sxpf.ErrorValue
+	if !errors.As(err, &ev) {
+		t.Fatalf("ErrorValue expected, but got %v", err)
+	}
+	if got := ev.Tag().String(); got != "MISSING" {
+		t.Errorf("tag MISSING expected, but got %v", got)
+	}
+	if got := err.Error(); got != "MISSING: field" {
+		t.Errorf("error message %q expected, but got %q", "MISSING: field", got)
+	}
+}
+
+func TestConditionCasePolicy(t *testing.T) {
+	testcases := []struct {
+		policy sxpf.CasePolicy
+		src    string
+		exp    string
+	}{
+		{sxpf.CasePreserving, "(handler-case (raise (quote oops)) (error (e) 1))", "1"},
+		{sxpf.CasePreserving, "(cond (#f 1) (else 2))", "2"},
+		{sxpf.CaseSensitive, "(HANDLER-CASE (RAISE (QUOTE oops)) (error (e) 1) (ERROR (e) 2))", "2"},
+	}
+	for i, tc := range testcases {
+		engine := sxpf.NewEngine(sxpf.NewSymbolMaker(tc.policy))
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := engine.Eval(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should evaluate to %v, but got %v", i, tc.src, tc.exp, got)
+		}
+	}
+}
+
+func TestErrorValueNilTag(t *testing.T) {
+	ev := sxpf.NewErrorValue(nil, "failed", nil)
+	if got := ev.Error(); got != "ERROR: failed" {
+		t.Errorf("error message %q expected, but got %q", "ERROR: failed", got)
+	}
+	if got := ev.String(); got != `#<error #:ERROR "failed">` {
+		t.Errorf("unexpected string %v", got)
+	}
+}
+
+func TestConditionFatal(t *testing.T) {
+	engine := newMacroTestEngine()
+	engine.SetMaxSteps(100)
+	expr, err := sxpf.ParseString(engine,
+		"(BEGIN (DEFINE (loop) (loop)) (HANDLER-CASE (UNWIND-PROTECT (loop) (QUOTE cleanup)) (ERROR () (QUOTE caught))))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := engine.Eval(expr)
+	var mse *sxpf.MaxStepsError
+	if !errors.As(err, &mse) {
+		t.Errorf("MaxStepsError expected, but got %v / %v", val, err)
+	}
+}
@@ -0,0 +1,257 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"context"
+	"fmt"
+)
+
+// Engine is an Environment that evaluates s-expressions with lexical scoping.
+//
+// Every Engine value represents one scope. Calling a closure or evaluating a
+// LET form creates a child scope, whose SymbolMap has the SymbolMap of the
+// enclosing scope as its parent. All scopes share the same SymbolMaker and
+// the same evaluation state. Therefore, an Engine must not be used
+// concurrently. Instead, create one engine per goroutine. Such engines may
+// share a SymbolMaker, e.g. one that was created by NewSymbolMaker.
+type Engine struct {
+	smk    SymbolMaker
+	symMap *SymbolMap
+	state  *engineState
+	local  bool // scope of a closure call, a LET, or a HANDLER-CASE form
+}
+
+// engineState is shared by all scopes of an engine.
+type engineState struct {
+	ctx       context.Context // not nil, while Eval / EvalContext is active
+	positions Positions
+	depth     uint
+	maxDepth  uint
+	steps     uint64
+	maxSteps  uint64
+	alloc     uint64
+	maxAlloc  uint64
+	gensym    *Gensym
+	meta      *Metadata
+
+	tracer       Tracer
+	breakHandler BreakHandler
+	traced       []Form
+	breakpoints  []Form
+	traceAll     bool
+	breakAll     bool
+	traceDepth   int
+}
+
+// NewEngine creates a new engine. Its top-level scope contains the core
+// special forms.
+func NewEngine(smk SymbolMaker) *Engine {
+	e := &Engine{
+		smk:    smk,
+		symMap: NewSymbolMap(nil),
+		state: &engineState{
+			maxDepth: 10000,
+			gensym:   NewGensym("G", 1),
+			meta:     NewMetadata(),
+		},
+	}
+	for _, form := range coreForms {
+		e.BindBuiltin(form)
+	}
+	return e
+}
+
+// NewChild creates a new scope, nested in the current one.
+func (e *Engine) NewChild() *Engine {
+	return &Engine{smk: e.smk, symMap: NewSymbolMap(e.symMap), state: e.state}
+}
+
+// newLocal creates a new scope for local bindings, nested in the current one.
+func (e *Engine) newLocal() *Engine {
+	scope := e.NewChild()
+	scope.local = true
+	return scope
+}
+
+// SetPositions sets the table of source positions, that is used to report
+// the position of form calls in an EvalError. The previous table is returned.
+// Typically, the table is filled by a Parser.
+func (e *Engine) SetPositions(ps Positions) Positions {
+	prevPs := e.state.positions
+	e.state.positions = ps
+	return prevPs
+}
+
+// Position returns the source position of the given value, if it is known.
+func (e *Engine) Position(val Value) (Position, bool) {
+	pos, found := e.state.positions[val]
+	return pos, found
+}
+
+// SetGensym sets the generator of new symbols, that is used by the GENSYM
+// form. The previous generator is returned.
+func (e *Engine) SetGensym(g *Gensym) *Gensym {
+	prevG := e.state.gensym
+	e.state.gensym = g
+	return prevG
+}
+
+// Metadata returns the metadata store of the engine, that is used by the
+// META and SET-META! forms.
+func (e *Engine) Metadata() *Metadata { return e.state.meta }
+
+// SetMetadata sets the metadata store of the engine. The previous store is
+// returned.
+func (e *Engine) SetMetadata(md *Metadata) *Metadata {
+	prevMd := e.state.meta
+	e.state.meta = md
+	return prevMd
+}
+
+// DocKey returns the metadata key of the documentation of a value, named
+// according to the case policy of the symbol maker.
+func (e *Engine) DocKey() *Keyword { return newKeyword("DOC", casePolicyOf(e.smk)) }
+
+// Doc returns the documentation of the given value. A string stored as
+// metadata DocKey takes precedence over the documentation of a builtin or a
+// closure.
+func (e *Engine) Doc(val Value) string {
+	if doc, found := e.state.meta.Get(val, e.DocKey()); found {
+		if s, isString := doc.(*String); isString {
+			return s.GetValue()
+		}
+	}
+	if d, ok := val.(Documented); ok {
+		return d.Doc()
+	}
+	return ""
+}
+
+// SymbolMap returns the symbol map of the current scope.
+func (e *Engine) SymbolMap() *SymbolMap { return e.symMap }
+
+// Define binds the symbol to the given value in the current scope. A
+// transient symbol is promoted, unless the scope is local to a closure call,
+// a LET, or a HANDLER-CASE form.
+func (e *Engine) Define(sym *Symbol, val Value) {
+	if !e.local {
+		sym.Promote()
+	}
+	e.symMap.Set(sym, val)
+}
+
+// BindBuiltin binds the builtin to the symbol of its name in the current scope.
+func (e *Engine) BindBuiltin(b *Builtin) { e.Define(e.MakeSymbol(b.Name()), b) }
+
+// MakeSymbol creates a symbol by using the symbol maker of the engine.
+func (e *Engine) MakeSymbol(s string) *Symbol { return e.smk.MakeSymbol(s) }
+
+// LookupForm returns the form bound to the given symbol.
+func (e *Engine) LookupForm(sym *Symbol) (Form, error) { return e.symMap.LookupForm(sym) }
+
+// EvaluateString returns the string itself.
+func (e *Engine) EvaluateString(str *String) (Value, error) {
+	if err := e.state.step(); err != nil {
+		return nil, err
+	}
+	return str, nil
+}
+
+// EvaluateSymbol returns the value bound to the symbol.
+func (e *Engine) EvaluateSymbol(sym *Symbol) (Value, error) {
+	if err := e.state.step(); err != nil {
+		return nil, err
+	}
+	if val, found := e.symMap.Lookup(sym); found {
+		return val, nil
+	}
+	return nil, ErrNotBound(sym)
+}
+
+// EvaluateList evaluates the list as a call of a form. A macro call is
+// expanded before. The empty list evaluates to itself.
+func (e *Engine) EvaluateList(p *Pair) (Value, error) {
+	if p == nil {
+		return p, nil
+	}
+	return e.evalAsCall(p, p.GetSlice())
+}
+
+// EvaluateVector evaluates the vector as a call of a form. A macro call is
+// expanded before. The empty vector evaluates to itself.
+func (e *Engine) EvaluateVector(v *Vector) (Value, error) {
+	vals := v.GetSlice()
+	if len(vals) == 0 {
+		return v, nil
+	}
+	return e.evalAsCall(v, vals)
+}
+
+func (e *Engine) evalAsCall(val Value, vals []Value) (Value, error) {
+	st := e.state
+	if err := st.step(); err != nil {
+		return nil, err
+	}
+	if st.depth >= st.maxDepth {
+		return nil, &MaxDepthError{st.maxDepth}
+	}
+	if err := st.allocate(len(vals)); err != nil {
+		return nil, err
+	}
+	st.depth++
+	defer func() { st.depth-- }()
+	if m := e.macroOf(vals); m != nil {
+		exp, err := m.Expand(e, vals[1:])
+		if err != nil {
+			return nil, err
+		}
+		return TailCall(e, exp), nil
+	}
+	res, err, done := evaluateCall(e, val, vals)
+	if done {
+		return res, err
+	}
+	return nil, fmt.Errorf("%v is not a form call", val)
+}
+
+// NotBoundError is returned as an error, if a symbol is not bound to a value.
+type NotBoundError struct {
+	Sym *Symbol
+}
+
+func (e *NotBoundError) Error() string {
+	return fmt.Sprintf("symbol %q not bound", e.Sym.GetValue())
+}
+
+// ErrNotBound creates an error.
+func ErrNotBound(sym *Symbol) error { return &NotBoundError{sym} }
+
+// Eval expands all macro calls of the given value and evaluates the result.
+func (e *Engine) Eval(val Value) (Value, error) {
+	return e.EvalContext(context.Background(), val)
+}
+
+// EvalContext expands all macro calls of the given value and evaluates the
+// result. The evaluation stops with a CanceledError, if the context is done.
+//
+// The number of evaluation steps and allocations are counted from the start
+// of the evaluation. If EvalContext is called while another evaluation of
+// the engine is active, e.g. by a builtin, the counting continues and the
+// context of the outer evaluation is used.
+func (e *Engine) EvalContext(ctx context.Context, val Value) (Value, error) {
+	defer e.state.start(ctx)()
+	exp, err := e.Expand(val)
+	if err != nil {
+		return nil, err
+	}
+	return Evaluate(e, exp)
+}
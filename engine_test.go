@@ -0,0 +1,204 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func newTestEngine() *sxpf.Engine {
+	engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+	for _, form := range testForms {
+		if form.Name() != "QUOTE" {
+			engine.BindBuiltin(form)
+		}
+	}
+	return engine
+}
+
+func TestEngine(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{`"a"`, `"a"`},
+		{"()", "()"},
+		{"[]", "[]"},
+		{"(QUOTE a)", "A"},
+		{"(CAT (QUOTE a) (QUOTE b))", `"AB"`},
+		{"[CAT (QUOTE a) (QUOTE b)]", `"AB"`},
+		{"(IF () (QUOTE a) (QUOTE b))", "B"},
+		{"(IF (QUOTE x) (QUOTE a) (QUOTE b))", "A"},
+		{"(IF () (QUOTE a))", "()"},
+		{"(IF #f (QUOTE a) (QUOTE b))", "B"},
+		{"(IF #t (QUOTE a) (QUOTE b))", "A"},
+		{"(IF [] (QUOTE a) (QUOTE b))", "A"},
+		{"#f", "#f"},
+		{"(COND (() (QUOTE a)) ((QUOTE x) (QUOTE b)))", "B"},
+		{"(COND (() (QUOTE a)) (ELSE (QUOTE c)))", "C"},
+		{"(COND ((QUOTE x)))", "X"},
+		{"(COND)", "()"},
+		{"(BEGIN)", "()"},
+		{"(BEGIN (QUOTE a) (QUOTE b))", "B"},
+		{"(BEGIN (DEFINE x (QUOTE a)) x)", "A"},
+		{"(DEFINE x (QUOTE a))", "X"},
+		{"(BEGIN (DEFINE f (LAMBDA (x) x)) f)", "#<closure F>"},
+		{"(LAMBDA (x) x)", "#<closure>"},
+		{"(BEGIN (DEFINE (f x) x) (f (QUOTE a)))", "A"},
+		{"(BEGIN (DEFINE (f . xs) xs) (f (QUOTE a) (QUOTE b)))", "(A B)"},
+		{"(BEGIN (DEFINE (f x . xs) xs) (f (QUOTE a)))", "()"},
+		{"(BEGIN (DEFINE f (LAMBDA xs xs)) (f (QUOTE a) (QUOTE b)))", "(A B)"},
+		{"(BEGIN (DEFINE f (LAMBDA [x y] (CAT y x))) (f (QUOTE a) (QUOTE b)))", `"BA"`},
+		{"(BEGIN (DEFINE (mk x) (LAMBDA (y) (CAT x y))) (DEFINE g (mk (QUOTE a))) (g (QUOTE b)))", `"AB"`},
+		{"(BEGIN (DEFINE x (QUOTE a)) (DEFINE (setx v) (SET! x v)) (setx (QUOTE b)) x)", "B"},
+		{"(BEGIN (DEFINE x (QUOTE a)) (DEFINE (f x) (SET! x (QUOTE c))) (f (QUOTE b)) x)", "A"},
+		{"(LET ((x (QUOTE a)) (y (QUOTE b))) (CAT x y))", `"AB"`},
+		{"(LET (x) x)", "()"},
+		{"(BEGIN (DEFINE x (QUOTE a)) (LET ((x (QUOTE b))) x))", "B"},
+		{"(BEGIN (DEFINE x (QUOTE a)) (LET ((x (QUOTE b))) (DEFINE x (QUOTE c))) x)", "A"},
+		{"(BEGIN (DEFINE (counter) (LET ((n ())) (LAMBDA () (SET! n (CAT n (QUOTE i)))))) (DEFINE c (counter)) (c) (c))", `"\"()I\"I"`},
+		{"((LAMBDA (x) x) (QUOTE a))", "A"},
+		{"[(LAMBDA (x) x) (QUOTE a)]", "A"},
+		{"([LAMBDA (x y) (CAT x y)] (QUOTE a) (QUOTE b))", `"AB"`},
+		{"(BEGIN (DEFINE (adder x) (LAMBDA (y) (CAT x y))) ((adder (QUOTE a)) (QUOTE b)))", `"AB"`},
+		{"((IF #t CAT QUOTE) (QUOTE a))", `"A"`},
+		{"(((LAMBDA () (LAMBDA () (QUOTE a)))))", "A"},
+	}
+	for i, tc := range testcases {
+		engine := newTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := sxpf.Evaluate(engine, expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		got := val.String()
+		if got != tc.exp {
+			t.Errorf("%d: %v should evaluate to %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+		if val, err = runCompiled(newTestEngine(), tc.src); err != nil || val.String() != tc.exp {
+			t.Errorf("%d: compiled %v should evaluate to %v, but got: %v / %v", i, tc.src, tc.exp, val, err)
+		}
+	}
+}
+
+func TestEngineError(t *testing.T) {
+	testcases := []struct {
+		src string
+		msg string
+	}{
+		{"x", `symbol "X" not bound`},
+		{"(x)", `symbol "X" not found to form`},
+		{`("a")`, `("a") is not a form call`},
+		{"((QUOTE a) 1)", "(QUOTE A) evaluates to A, which is not a form"},
+		{"([] 1)", "([] 1) is not a form call"},
+		{"((x) 1)", `symbol "X" not found to form`},
+		{"(SET! x (QUOTE a))", `symbol "X" not bound`},
+		{"(BEGIN (DEFINE (f x) x) (f))", "not enough arguments (0) for form #<closure F> (1)"},
+		{"(BEGIN (DEFINE (f x) x) (f () ()))", "too many arguments (2) for form #<closure F> (1)"},
+		{`(LAMBDA ("x") x)`, `parameter "x" is not a symbol`},
+		{"(LET ((x)) x)", "LET binding (X) must have two elements"},
+		{"(IF)", "not enough arguments (0) for form IF (2)"},
+	}
+	for i, tc := range testcases {
+		engine := newTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := sxpf.Evaluate(engine, expr)
+		if err == nil {
+			t.Errorf("%d: %v should result in error, but got: %v", i, tc.src, val)
+			continue
+		}
+		if got := err.Error(); got != tc.msg {
+			t.Errorf("%d: %v should result in error %q, but got %q", i, tc.src, tc.msg, got)
+		}
+		if val, err = runCompiled(newTestEngine(), tc.src); err == nil || err.Error() != tc.msg {
+			t.Errorf("%d: compiled %v should result in error %q, but got: %v / %v", i, tc.src, tc.msg, val, err)
+		}
+	}
+}
+
+var restForm = sxpf.NewBuiltin(
+	"REST",
+	false, 1, 1,
+	func(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+		if p, ok := args[0].(*sxpf.Pair); ok && p != nil {
+			return p.GetSecond(), nil
+		}
+		return sxpf.Nil(), nil
+	},
+)
+
+func TestEngineTailCall(t *testing.T) {
+	testcases := []string{
+		"(BEGIN (DEFINE (walk xs) (IF xs (walk (REST xs)) (QUOTE done))) (walk lst))",
+		"(BEGIN (DEFINE (walk xs) (COND (xs (walk (REST xs))) (ELSE (QUOTE done)))) (walk lst))",
+		"(BEGIN (DEFINE (walk xs) (LET ((ys (REST xs))) (IF ys (BEGIN (walk ys)) (QUOTE done)))) (walk lst))",
+		"(BEGIN (DEFINE (even xs) (IF xs (odd (REST xs)) (QUOTE done))) (DEFINE (odd xs) (even (REST xs))) (even lst))",
+	}
+	lst := make([]sxpf.Value, 100000)
+	for i := range lst {
+		lst[i] = sxpf.NewString("")
+	}
+	for i, src := range testcases {
+		engine := newTestEngine()
+		engine.SetMaxDepth(50)
+		engine.BindBuiltin(restForm)
+		engine.Define(engine.MakeSymbol("lst"), sxpf.NewPairFromSlice(lst))
+		expr, err := sxpf.ParseString(engine, src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := sxpf.Evaluate(engine, expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, src, err)
+			continue
+		}
+		if got := val.String(); got != "DONE" {
+			t.Errorf("%d: %v should evaluate to DONE, but got: %v", i, src, got)
+		}
+		code, err := engine.Compile(expr)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if val, err = code.Run(); err != nil || val.String() != "DONE" {
+			t.Errorf("%d: compiled %v should evaluate to DONE, but got: %v / %v", i, src, val, err)
+		}
+	}
+}
+
+func TestEngineMaxDepth(t *testing.T) {
+	engine := newTestEngine()
+	expr, err := sxpf.ParseString(engine, "(BEGIN (DEFINE (f) (CAT (f))) (f))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := sxpf.Evaluate(engine, expr)
+	var mde *sxpf.MaxDepthError
+	if !errors.As(err, &mde) {
+		t.Fatalf("MaxDepthError expected, but got %v / %v", val, err)
+	}
+	if mde.MaxDepth != 10000 {
+		t.Errorf("expected max depth 10000, but got %d", mde.MaxDepth)
+	}
+}
@@ -10,11 +10,15 @@
 
 package sxpf
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type SymbolMaker interface {
 	// MakeSymbol creates a new or uses an existing symbol with the given
-	// string value.
+	// string value. It returns nil, if no symbol can be created, e.g.
+	// because a limit was reached.
 	MakeSymbol(string) *Symbol
 }
 
@@ -23,10 +27,21 @@ type trivialSymbolMaker struct {
 }
 
 // NewTrivialSymbolMaker creates a new SymbolMaker, that makes unique symbols.
+// Symbol names are converted to upper case.
 func NewTrivialSymbolMaker() SymbolMaker {
 	return &trivialSymbolMaker{NewSymbolTable()}
 }
+
+// NewSymbolMaker creates a new SymbolMaker, that makes unique symbols and
+// handles the case of symbol names according to the given policy. Like all
+// symbol makers based on a SymbolTable, it may be used concurrently.
+func NewSymbolMaker(policy CasePolicy) SymbolMaker {
+	return &trivialSymbolMaker{NewSymbolTableWithPolicy(policy)}
+}
 func (smk *trivialSymbolMaker) MakeSymbol(s string) *Symbol { return smk.symbols.MakeSymbol(s) }
+func (smk *trivialSymbolMaker) findSymbol(s string) (*Symbol, bool) {
+	return smk.symbols.findSymbol(s)
+}
 
 // Environment provides methods to evaluate a s-expression.
 type Environment interface {
@@ -52,7 +67,21 @@ type Environment interface {
 }
 
 // Evaluate the given s-expression value in the given environment.
+//
+// If the evaluation results in a tail call (see TailCall), the evaluation
+// continues with the value and environment of the tail call. Therefore, tail
+// calls do not grow the Go stack.
+//
+// If the environment belongs to an Engine, that does not evaluate another
+// value, the limits of the engine are counted from the start.
 func Evaluate(env Environment, value Value) (Value, error) {
+	if st := stateOf(env); st != nil && st.ctx == nil {
+		defer st.start(context.Background())()
+	}
+	return resolveTailCall(evaluateOnce(env, value))
+}
+
+func evaluateOnce(env Environment, value Value) (Value, error) {
 	switch val := value.(type) {
 	case *Symbol:
 		return env.EvaluateSymbol(val)
@@ -69,28 +98,81 @@ func Evaluate(env Environment, value Value) (Value, error) {
 }
 
 // EvaluateCall by trying to evaluate the first slice element as a form.
-// If the first slice element is a form, the last returned value is true.
+// If the first slice element denotes a form, the last returned value is
+// true.
+//
+// A symbol denotes the form bound to it. A non-empty pair list or vector,
+// e.g. a LAMBDA expression, is evaluated and must result in a form. A form
+// denotes itself. Other values, like strings, do not denote a form.
+//
+// The result of a form may be a tail call. Therefore, EvaluateList and
+// EvaluateVector should return the result of EvaluateCall unchanged, so that
+// Evaluate is able to process it.
+//
+// If the call fails, the error is an EvalError, that records the call.
 func EvaluateCall(env Environment, vals []Value) (Value, error, bool) {
-	if len(vals) == 0 {
+	return evaluateCall(env, nil, vals)
+}
+
+// evaluateCall evaluates vals as a form call. Expr is the expression of the
+// call, and is used to report errors. If it is nil, the expression is
+// created from vals.
+func evaluateCall(env Environment, expr Value, vals []Value) (Value, error, bool) {
+	if len(vals) == 0 || !isCallHead(vals[0]) {
 		return nil, nil, false
 	}
-	if sym, ok := vals[0].(*Symbol); ok {
-		form, err := env.LookupForm(sym)
+	if expr == nil {
+		expr = NewPairFromSlice(vals)
+	}
+	form, err := evaluateHead(env, vals[0])
+	if err != nil {
+		return nil, addFrame(env, err, expr, nil, nil), true
+	}
+	params := vals[1:]
+	if !form.IsSpecial() {
+		var err error
+		params, err = EvaluateSlice(env, params)
 		if err != nil {
-			return nil, err, true
+			return nil, addFrame(env, err, expr, form, nil), true
 		}
-		params := vals[1:]
-		if !form.IsSpecial() {
-			var err error
-			params, err = EvaluateSlice(env, params)
-			if err != nil {
-				return nil, err, true
-			}
-		}
-		res, err := form.Call(env, params)
-		return res, err, true
 	}
-	return nil, nil, false
+	res, err := callForm(env, expr, form, params)
+	if err != nil {
+		return nil, addFrame(env, err, expr, form, params), true
+	}
+	return res, nil, true
+}
+
+// isCallHead returns true, if the value denotes a form in the head position
+// of a call.
+func isCallHead(head Value) bool {
+	switch h := head.(type) {
+	case *Symbol, Form:
+		return true
+	case *Pair:
+		return h != nil
+	case *Vector:
+		return len(h.GetSlice()) > 0
+	}
+	return false
+}
+
+// evaluateHead returns the form denoted by the head of a call.
+func evaluateHead(env Environment, head Value) (Form, error) {
+	switch h := head.(type) {
+	case *Symbol:
+		return env.LookupForm(h)
+	case Form:
+		return h, nil
+	}
+	val, err := Evaluate(env, head)
+	if err != nil {
+		return nil, err
+	}
+	if form, ok := val.(Form); ok {
+		return form, nil
+	}
+	return nil, ErrNotForm(head, val)
 }
 
 // EvaluateSlice by evaluating all slice elements, returning a slice of
@@ -106,6 +188,58 @@ func EvaluateSlice(env Environment, vals []Value) (res []Value, err error) {
 	return res, nil
 }
 
+// TailCall returns a value that instructs Evaluate to continue with
+// evaluating the given value in the given environment. A form may return it
+// instead of evaluating a value in tail position by itself. Since Evaluate
+// processes tail calls in a loop, a chain of tail calls uses a constant
+// amount of Go stack.
+func TailCall(env Environment, val Value) Value { return &tailCall{env: env, val: val} }
+
+// resolveTailCall evaluates a tail call, that was returned by calling a form
+// directly, i.e. without using Evaluate.
+func resolveTailCall(res Value, err error) (Value, error) {
+	var exits []*traceExit
+	for err == nil {
+		tc, ok := res.(*tailCall)
+		if !ok {
+			break
+		}
+		exits = append(exits, tc.exits...)
+		if tc.form == nil {
+			res, err = evaluateOnce(tc.env, tc.val)
+		} else if res, err = callForm(tc.env, tc.val, tc.form, tc.args); err != nil {
+			err = addFrame(tc.env, err, tc.val, tc.form, tc.args)
+		}
+	}
+	for i := len(exits) - 1; i >= 0; i-- {
+		exits[i].report(res, err)
+	}
+	return res, err
+}
+
+// tailCall is the result of a form, that must be evaluated further. If form
+// is not nil, it is called with the already evaluated arguments, and val is
+// the expression of the call.
+type tailCall struct {
+	env   Environment
+	val   Value
+	form  Form
+	args  []Value
+	exits []*traceExit // traced calls, that return the result of the tail call
+}
+
+func (tc *tailCall) Equal(other Value) bool {
+	if tc == nil || other == nil {
+		return tc == other
+	}
+	if o, ok := other.(*tailCall); ok {
+		return tc.env == o.env && tc.val.Equal(o.val)
+	}
+	return false
+}
+
+func (tc *tailCall) String() string { return "#<tail-call " + tc.val.String() + ">" }
+
 // NotFormBoundError is returned as an error, if a symbol is not bound to a form.
 type NotFormBoundError struct {
 	Sym *Symbol
@@ -117,3 +251,17 @@ func (e *NotFormBoundError) Error() string {
 
 // ErrNotFormBound creates an error.
 func ErrNotFormBound(sym *Symbol) error { return &NotFormBoundError{sym} }
+
+// NotFormError is returned as an error, if the head of a call does not
+// evaluate to a form.
+type NotFormError struct {
+	Head Value
+	Val  Value
+}
+
+func (e *NotFormError) Error() string {
+	return fmt.Sprintf("%v evaluates to %v, which is not a form", e.Head, e.Val)
+}
+
+// ErrNotForm creates an error.
+func ErrNotForm(head, val Value) error { return &NotFormError{head, val} }
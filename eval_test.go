@@ -48,19 +48,20 @@ func TestEvaluate(t *testing.T) {
 	}
 }
 
-type testEnv struct {
-	symbols sxpf.SymbolTable
-	symMap  *sxpf.SymbolMap
-}
-
-func newTestEnv() *testEnv {
-	env := testEnv{symbols: sxpf.NewSymbolTable()}
-	symMap := sxpf.NewSymbolMap(nil)
+func newTestEnv() *sxpf.BasicEnvironment {
+	env := sxpf.NewBasicEnvironment(nil, sxpf.EvalStrategy{
+		Symbol: func(_ *sxpf.BasicEnvironment, sym *sxpf.Symbol) (sxpf.Value, error) { return sym, nil },
+		List: func(env *sxpf.BasicEnvironment, p *sxpf.Pair) (sxpf.Value, error) {
+			return evalAsCall(env, p.GetSlice())
+		},
+		Vector: func(env *sxpf.BasicEnvironment, v *sxpf.Vector) (sxpf.Value, error) {
+			return evalAsCall(env, v.GetSlice())
+		},
+	})
 	for _, form := range testForms {
-		symMap.Set(env.MakeSymbol(form.Name()), form)
+		env.BindBuiltin(form)
 	}
-	env.symMap = symMap
-	return &env
+	return env
 }
 
 var testForms = []*sxpf.Builtin{
@@ -84,21 +85,12 @@ var testForms = []*sxpf.Builtin{
 	),
 }
 
-func (te *testEnv) MakeSymbol(s string) *sxpf.Symbol                 { return te.symbols.MakeSymbol(s) }
-func (te *testEnv) LookupForm(sym *sxpf.Symbol) (sxpf.Form, error)   { return te.symMap.LookupForm(sym) }
-func (*testEnv) EvaluateSymbol(sym *sxpf.Symbol) (sxpf.Value, error) { return sym, nil }
-func (*testEnv) EvaluateString(str *sxpf.String) (sxpf.Value, error) { return str, nil }
-func (te *testEnv) EvaluateList(p *sxpf.Pair) (sxpf.Value, error)    { return te.evalAsCall(p.GetSlice()) }
-func (te *testEnv) EvaluateVector(lst *sxpf.Vector) (sxpf.Value, error) {
-	return te.evalAsCall(lst.GetSlice())
-}
-
-func (te *testEnv) evalAsCall(vals []sxpf.Value) (sxpf.Value, error) {
-	res, err, done := sxpf.EvaluateCall(te, vals)
+func evalAsCall(env sxpf.Environment, vals []sxpf.Value) (sxpf.Value, error) {
+	res, err, done := sxpf.EvaluateCall(env, vals)
 	if done {
 		return res, err
 	}
-	result, err := sxpf.EvaluateSlice(te, vals)
+	result, err := sxpf.EvaluateSlice(env, vals)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,90 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// EvalError is returned as an error, if the evaluation of a form call failed.
+// It records the chain of form calls that were evaluated when the error
+// occurred. Calls in tail position are not recorded, since they replaced
+// their caller.
+type EvalError struct {
+	Err     error       // the underlying error
+	Frames  []EvalFrame // innermost call first
+	Omitted int         // number of outer calls that were not recorded
+}
+
+// EvalFrame describes one form call that was evaluated.
+type EvalFrame struct {
+	Expr Value    // the expression of the call
+	Pos  Position // source position of the expression, if known
+	Form Form     // the called form, nil if it was not found
+	Args []Value  // the arguments, nil if they could not be evaluated
+}
+
+// maxEvalFrames is the maximum number of frames recorded in an EvalError.
+const maxEvalFrames = 100
+
+// Positioner is implemented by environments that know the source positions
+// of values.
+type Positioner interface {
+	// Position returns the source position of the given value.
+	Position(Value) (Position, bool)
+}
+
+func (e *EvalError) Error() string { return e.Err.Error() }
+func (e *EvalError) Unwrap() error { return e.Err }
+
+// Traceback returns the error message, together with all recorded calls.
+func (e *EvalError) Traceback() string {
+	var buf bytes.Buffer
+	buf.WriteString(e.Err.Error())
+	for _, frame := range e.Frames {
+		buf.WriteString("\n  in ")
+		buf.WriteString(frame.Expr.String())
+		if frame.Pos.IsValid() {
+			fmt.Fprintf(&buf, " at %v", frame.Pos)
+		}
+		if frame.Args != nil {
+			buf.WriteString("\n    args:")
+			for _, arg := range frame.Args {
+				buf.WriteByte(' ')
+				buf.WriteString(arg.String())
+			}
+		}
+	}
+	if e.Omitted > 0 {
+		fmt.Fprintf(&buf, "\n  ... %d more", e.Omitted)
+	}
+	return buf.String()
+}
+
+// addFrame adds the description of a form call to the given error. If the
+// error is not an EvalError, it will be wrapped into one.
+func addFrame(env Environment, err error, expr Value, form Form, args []Value) error {
+	ee, ok := err.(*EvalError)
+	if !ok {
+		ee = &EvalError{Err: err}
+	}
+	if len(ee.Frames) >= maxEvalFrames {
+		ee.Omitted++
+		return ee
+	}
+	frame := EvalFrame{Expr: expr, Form: form, Args: args}
+	if p, isPositioner := env.(Positioner); isPositioner {
+		frame.Pos, _ = p.Position(expr)
+	}
+	ee.Frames = append(ee.Frames, frame)
+	return ee
+}
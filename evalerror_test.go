@@ -0,0 +1,90 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestEvalError(t *testing.T) {
+	engine := newTestEngine()
+	pa := sxpf.NewParser(engine, strings.NewReader(
+		"(BEGIN\n  (DEFINE (f x)\n    (CAT x (g)) x)\n  (f (QUOTE a))\n  (QUOTE b))"))
+	ps := sxpf.Positions{}
+	pa.SetPositions(ps)
+	engine.SetPositions(ps)
+	expr, err := pa.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := engine.Eval(expr)
+	var ee *sxpf.EvalError
+	if !errors.As(err, &ee) {
+		t.Fatalf("EvalError expected, but got %v / %v", val, err)
+	}
+	var nfbe *sxpf.NotFormBoundError
+	if !errors.As(err, &nfbe) {
+		t.Errorf("error %v should wrap a NotFormBoundError", err)
+	}
+	if got, exp := err.Error(), `symbol "G" not found to form`; got != exp {
+		t.Errorf("error message should be %q, but got %q", exp, got)
+	}
+	exp := `symbol "G" not found to form
+  in (G) at 3:12
+  in (CAT X (G)) at 3:5
+  in (F (QUOTE A)) at 4:3
+    args: A
+  in (BEGIN (DEFINE (F X) (CAT X (G)) X) (F (QUOTE A)) (QUOTE B)) at 1:1
+    args: (DEFINE (F X) (CAT X (G)) X) (F (QUOTE A)) (QUOTE B)`
+	if got := ee.Traceback(); got != exp {
+		t.Errorf("traceback should be\n%v\nbut got\n%v", exp, got)
+	}
+	if len(ee.Frames) != 4 {
+		t.Fatalf("4 frames expected, but got %d", len(ee.Frames))
+	}
+	if form := ee.Frames[0].Form; form != nil {
+		t.Errorf("no form expected for first frame, but got %v", form)
+	}
+	if form := ee.Frames[1].Form; form == nil || form.String() != "#CAT" {
+		t.Errorf("form CAT expected for second frame, but got %v", form)
+	}
+
+	code, err := engine.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = code.Run(); !errors.As(err, &ee) || ee.Traceback() != exp {
+		t.Errorf("compiled traceback should be\n%v\nbut got\n%v", exp, err)
+	}
+}
+
+func TestEvalErrorOmitted(t *testing.T) {
+	engine := newTestEngine()
+	expr, err := sxpf.ParseString(engine, "(BEGIN (DEFINE (f) (CAT (f))) (f))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = engine.Eval(expr)
+	var ee *sxpf.EvalError
+	if !errors.As(err, &ee) {
+		t.Fatalf("EvalError expected, but got %v", err)
+	}
+	if len(ee.Frames) != 100 || ee.Omitted == 0 {
+		t.Errorf("100 frames and some omitted frames expected, but got %d / %d", len(ee.Frames), ee.Omitted)
+	}
+	if !strings.HasSuffix(ee.Traceback(), " more") {
+		t.Errorf("traceback should mention omitted frames:\n%v", ee.Traceback())
+	}
+}
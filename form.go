@@ -28,6 +28,9 @@ type Builtin struct {
 	minArity int
 	maxArity int // if maxArity < minArity ==> maxArity is unlimited
 	special  bool
+	keywords []KeywordParam
+	doc      string
+	pure     bool
 }
 
 // BuiltinFn is a builtin form that is implemented in Go.
@@ -35,7 +38,7 @@ type BuiltinFn func(Environment, []Value) (Value, error)
 
 // NewBuiltin returns a new builtin form.
 func NewBuiltin(name string, special bool, minArity, maxArity int, f BuiltinFn) *Builtin {
-	return &Builtin{name, f, minArity, maxArity, special}
+	return &Builtin{name: name, fn: f, minArity: minArity, maxArity: maxArity, special: special}
 }
 
 func (b *Builtin) Equal(other Value) bool {
@@ -58,8 +61,52 @@ func (b *Builtin) Name() string {
 	return b.name
 }
 
+// WithDoc returns a copy of the builtin, that is documented by the given
+// string.
+func (b *Builtin) WithDoc(doc string) *Builtin {
+	result := *b
+	result.doc = doc
+	return &result
+}
+
+// Doc returns the documentation of the builtin.
+func (b *Builtin) Doc() string {
+	if b == nil {
+		return ""
+	}
+	return b.doc
+}
+
+// WithPure returns a copy of the builtin, that is marked as pure: it has no
+// side effects, and its result depends only on its arguments. Engine.Compile
+// evaluates calls of a pure builtin with constant arguments at compile time.
+// Since all runs of the code share the result, only builtins that return
+// immutable scalar values, like integers, booleans, or strings, should be
+// marked as pure.
+func (b *Builtin) WithPure() *Builtin {
+	result := *b
+	result.pure = true
+	return &result
+}
+
+// IsPure returns true, if the builtin is marked as pure.
+func (b *Builtin) IsPure() bool { return b != nil && b.pure }
+
+// acceptsArity returns true, if the builtin accepts the given number of
+// arguments without keyword arguments.
+func (b *Builtin) acceptsArity(n int) bool {
+	return len(b.keywords) == 0 && b.minArity <= n && (b.maxArity < b.minArity || n <= b.maxArity)
+}
+
 func (b *Builtin) Call(env Environment, args []Value) (Value, error) {
-	if length := len(args); length < b.minArity {
+	length := len(args)
+	if len(b.keywords) > 0 {
+		var err error
+		if args, length, err = b.parseKeywordArgs(args); err != nil {
+			return nil, err
+		}
+	}
+	if length < b.minArity {
 		return nil, fmt.Errorf("not enough arguments (%d) for form %v (%d)", length, b.name, b.minArity)
 	} else if b.minArity <= b.maxArity && b.maxArity < length {
 		return nil, fmt.Errorf("too many arguments (%d) for form %v (%d)", length, b.name, b.maxArity)
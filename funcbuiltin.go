@@ -0,0 +1,255 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// NewFuncBuiltin returns a new builtin form that calls the given Go function.
+//
+// The arity of the builtin is derived from the signature of the function.
+// Arguments are converted into the types of the parameters; if this is not
+// possible, a descriptive error is returned. A variadic parameter takes all
+// remaining arguments. Supported parameter types are string (a string or a
+// symbol), int64 and int (an integer), rune (a character), []byte (a byte
+// string), bool (the truth value of any value, see IsTrue), []Value (the
+// elements of a sequence), and every type that implements Value, e.g.
+// *Symbol, Sequence, or Value itself. If the first parameter is an
+// Environment, it receives the environment of the call and does not count as
+// an argument.
+//
+// The function may return nothing, a result, an error, or a result and an
+// error. Results of type string, int64, int, rune, []byte, bool, and []Value
+// are converted into a String, an Integer, a Char, Bytes, a Boolean, and a
+// pair list. A nil result is converted into the empty list.
+//
+// An error is returned, if the function has an unsupported signature.
+func NewFuncBuiltin(name string, fn interface{}) (*Builtin, error) {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("builtin %v: %v is not a function", name, ft)
+	}
+	withEnv := ft.NumIn() > 0 && ft.In(0) == environmentType
+	first := 0
+	if withEnv {
+		first = 1
+	}
+	params := make([]argConverter, 0, ft.NumIn()-first)
+	for i := first; i < ft.NumIn(); i++ {
+		pt := ft.In(i)
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			pt = pt.Elem()
+		}
+		conv := makeArgConverter(pt)
+		if conv == nil {
+			return nil, fmt.Errorf("builtin %v: unsupported parameter type %v", name, pt)
+		}
+		params = append(params, conv)
+	}
+	res, err := makeResultConverter(ft)
+	if err != nil {
+		return nil, fmt.Errorf("builtin %v: %w", name, err)
+	}
+
+	minArity, maxArity := len(params), len(params)
+	if ft.IsVariadic() {
+		minArity, maxArity = minArity-1, -1
+	}
+	return NewBuiltin(
+		name,
+		false, minArity, maxArity,
+		func(env Environment, args []Value) (Value, error) {
+			in := make([]reflect.Value, 0, first+len(args))
+			if withEnv {
+				if env == nil {
+					in = append(in, reflect.Zero(environmentType))
+				} else {
+					in = append(in, reflect.ValueOf(env))
+				}
+			}
+			for i := range args {
+				conv := params[len(params)-1]
+				if i < len(params) {
+					conv = params[i]
+				}
+				arg, err2 := conv(args, i)
+				if err2 != nil {
+					return nil, err2
+				}
+				in = append(in, arg)
+			}
+			return res(fv.Call(in))
+		},
+	), nil
+}
+
+// MustNewFuncBuiltin is like NewFuncBuiltin, but panics if the function has
+// an unsupported signature. It simplifies the initialization of global
+// variables.
+func MustNewFuncBuiltin(name string, fn interface{}) *Builtin {
+	b, err := NewFuncBuiltin(name, fn)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var (
+	environmentType = reflect.TypeOf((*Environment)(nil)).Elem()
+	valueType       = reflect.TypeOf((*Value)(nil)).Elem()
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+	valueSliceType  = reflect.TypeOf([]Value(nil))
+	stringType      = reflect.TypeOf("")
+	int64Type       = reflect.TypeOf(int64(0))
+	intType         = reflect.TypeOf(0)
+	runeType        = reflect.TypeOf(rune(0))
+	bytesType       = reflect.TypeOf([]byte(nil))
+	boolType        = reflect.TypeOf(false)
+)
+
+// argConverter converts the idx value of args into a value of a parameter
+// type.
+type argConverter func(args []Value, idx int) (reflect.Value, error)
+
+func makeArgConverter(pt reflect.Type) argConverter {
+	switch pt {
+	case stringType:
+		return func(args []Value, idx int) (reflect.Value, error) {
+			s, err := GetString(args, idx)
+			return reflect.ValueOf(s), err
+		}
+	case int64Type, intType:
+		return func(args []Value, idx int) (reflect.Value, error) {
+			i, err := GetInteger(args, idx)
+			return reflect.ValueOf(i).Convert(pt), err
+		}
+	case runeType:
+		return func(args []Value, idx int) (reflect.Value, error) {
+			r, err := GetChar(args, idx)
+			return reflect.ValueOf(r), err
+		}
+	case bytesType:
+		return func(args []Value, idx int) (reflect.Value, error) {
+			b, err := GetBytes(args, idx)
+			return reflect.ValueOf(b), err
+		}
+	case boolType:
+		return func(args []Value, idx int) (reflect.Value, error) {
+			return reflect.ValueOf(IsTrue(args[idx])), nil
+		}
+	case valueSliceType:
+		return func(args []Value, idx int) (reflect.Value, error) {
+			seq, err := GetSequence(args, idx)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(seq.GetSlice()), nil
+		}
+	}
+	if !pt.Implements(valueType) {
+		return nil
+	}
+	return func(args []Value, idx int) (reflect.Value, error) {
+		arg := args[idx]
+		if arg == nil {
+			return reflect.Zero(pt), nil
+		}
+		av := reflect.ValueOf(arg)
+		if !av.Type().AssignableTo(pt) {
+			return reflect.Value{}, fmt.Errorf("%v / %d is not %s", arg, idx, typeDescription(pt))
+		}
+		v := reflect.New(pt).Elem()
+		v.Set(av)
+		return v, nil
+	}
+}
+
+// typeDescription returns a readable description of a value type.
+func typeDescription(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf((*Symbol)(nil)):
+		return "a symbol"
+	case reflect.TypeOf((*String)(nil)):
+		return "a string"
+	case reflect.TypeOf((*Integer)(nil)):
+		return "an integer"
+	case reflect.TypeOf((*Pair)(nil)):
+		return "a list"
+	case reflect.TypeOf((*Vector)(nil)):
+		return "a vector"
+	case reflect.TypeOf((*Sequence)(nil)).Elem():
+		return "a sequence"
+	case reflect.TypeOf((*Form)(nil)).Elem():
+		return "a form"
+	}
+	return "of type " + t.String()
+}
+
+// resultConverter converts the results of a function call into the results
+// of a builtin.
+type resultConverter func([]reflect.Value) (Value, error)
+
+func makeResultConverter(ft reflect.Type) (resultConverter, error) {
+	numOut := ft.NumOut()
+	withErr := numOut > 0 && ft.Out(numOut-1) == errorType
+	if withErr {
+		numOut--
+	}
+	if numOut > 1 {
+		return nil, fmt.Errorf("too many results")
+	}
+	var conv func(reflect.Value) Value
+	if numOut == 1 {
+		if conv = makeValueConverter(ft.Out(0)); conv == nil {
+			return nil, fmt.Errorf("unsupported result type %v", ft.Out(0))
+		}
+	}
+	return func(out []reflect.Value) (Value, error) {
+		if withErr {
+			if errVal := out[len(out)-1]; !errVal.IsNil() {
+				return nil, errVal.Interface().(error)
+			}
+		}
+		if conv == nil {
+			return Nil(), nil
+		}
+		return conv(out[0]), nil
+	}, nil
+}
+
+func makeValueConverter(rt reflect.Type) func(reflect.Value) Value {
+	switch rt {
+	case stringType:
+		return func(v reflect.Value) Value { return NewString(v.String()) }
+	case int64Type, intType:
+		return func(v reflect.Value) Value { return NewInteger(v.Int()) }
+	case runeType:
+		return func(v reflect.Value) Value { return NewChar(rune(v.Int())) }
+	case bytesType:
+		return func(v reflect.Value) Value { return NewBytes(v.Bytes()) }
+	case boolType:
+		return func(v reflect.Value) Value { return MakeBoolean(v.Bool()) }
+	case valueSliceType:
+		return func(v reflect.Value) Value { return NewPairFromSlice(v.Interface().([]Value)) }
+	}
+	if !rt.Implements(valueType) {
+		return nil
+	}
+	return func(v reflect.Value) Value {
+		if val, ok := v.Interface().(Value); ok && val != nil {
+			return val
+		}
+		return Nil()
+	}
+}
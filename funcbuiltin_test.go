@@ -0,0 +1,114 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestFuncBuiltin(t *testing.T) {
+	repeat := sxpf.MustNewFuncBuiltin("REPEAT", func(name string, n int64, xs []sxpf.Value) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(name, int(n)) + sxpf.NewPairFromSlice(xs).String(), nil
+	})
+	join := sxpf.MustNewFuncBuiltin("JOIN", func(sep string, parts ...string) string {
+		return strings.Join(parts, sep)
+	})
+	first := sxpf.MustNewFuncBuiltin("FIRST", func(p *sxpf.Pair) sxpf.Value { return p.GetFirst() })
+	count := sxpf.MustNewFuncBuiltin("COUNT", func(env sxpf.Environment, vals ...sxpf.Value) int {
+		if env == nil {
+			return -1
+		}
+		return len(vals)
+	})
+	not := sxpf.MustNewFuncBuiltin("NOT", func(b bool) bool { return !b })
+	upper := sxpf.MustNewFuncBuiltin("UPPER", unicode.ToUpper)
+	checksum := sxpf.MustNewFuncBuiltin("CHECKSUM", func(data []byte) []byte {
+		sum := byte(0)
+		for _, b := range data {
+			sum += b
+		}
+		return []byte{sum}
+	})
+	nothing := sxpf.MustNewFuncBuiltin("NOTHING", func() {})
+
+	testcases := []struct {
+		b    *sxpf.Builtin
+		args []sxpf.Value
+		exp  string
+		msg  string
+	}{
+		{repeat, []sxpf.Value{sxpf.NewString("a"), sxpf.NewInteger(3), sxpf.NewVector(sxpf.NewString("x"))}, `"aaa(\"x\")"`, ""},
+		{repeat, []sxpf.Value{sxpf.NewString("a"), sxpf.NewInteger(-1), sxpf.Nil()}, "", "negative count"},
+		{repeat, []sxpf.Value{sxpf.NewString("a"), sxpf.NewString("b"), sxpf.Nil()}, "", `"b" / 1 is not an integer`},
+		{repeat, []sxpf.Value{sxpf.NewString("a"), sxpf.NewInteger(1)}, "", "not enough arguments (2) for form REPEAT (3)"},
+		{join, []sxpf.Value{sxpf.NewString("-")}, `""`, ""},
+		{join, []sxpf.Value{sxpf.NewString("-"), sxpf.NewString("a"), sxpf.NewString("b")}, `"a-b"`, ""},
+		{join, []sxpf.Value{sxpf.NewString("-"), sxpf.NewString("a"), sxpf.NewInteger(1)}, "", "1 / 2 is not a string"},
+		{join, nil, "", "not enough arguments (0) for form JOIN (1)"},
+		{first, []sxpf.Value{sxpf.NewPairFromSlice([]sxpf.Value{sxpf.NewString("a")})}, `"a"`, ""},
+		{first, []sxpf.Value{sxpf.NewVector()}, "", "[] / 0 is not a list"},
+		{first, []sxpf.Value{sxpf.NewString("a"), sxpf.NewString("b")}, "", "too many arguments (2) for form FIRST (1)"},
+		{count, []sxpf.Value{sxpf.NewString("a"), sxpf.Nil()}, "2", ""},
+		{not, []sxpf.Value{sxpf.Nil()}, "#t", ""},
+		{not, []sxpf.Value{sxpf.NewVector()}, "#f", ""},
+		{upper, []sxpf.Value{sxpf.NewChar('a')}, `#\A`, ""},
+		{upper, []sxpf.Value{sxpf.NewString("a")}, "", `"a" / 0 is not a character`},
+		{checksum, []sxpf.Value{sxpf.NewBytes([]byte{1, 2, 3})}, `#base64"Bg=="`, ""},
+		{nothing, nil, "()", ""},
+	}
+	env := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+	for i, tc := range testcases {
+		val, err := tc.b.Call(env, tc.args)
+		if tc.msg != "" {
+			if err == nil {
+				t.Errorf("%d: %v should result in error, but got: %v", i, tc.b, val)
+			} else if got := err.Error(); got != tc.msg {
+				t.Errorf("%d: %v should result in error %q, but got %q", i, tc.b, tc.msg, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.b, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should result in %v, but got: %v", i, tc.b, tc.exp, got)
+		}
+	}
+}
+
+func TestFuncBuiltinSignature(t *testing.T) {
+	testcases := []struct {
+		fn  interface{}
+		msg string
+	}{
+		{17, "builtin F: int is not a function"},
+		{func(float64) {}, "builtin F: unsupported parameter type float64"},
+		{func() (int, int) { return 0, 0 }, "builtin F: too many results"},
+		{func() float64 { return 0 }, "builtin F: unsupported result type float64"},
+	}
+	for i, tc := range testcases {
+		_, err := sxpf.NewFuncBuiltin("F", tc.fn)
+		if err == nil {
+			t.Errorf("%d: %T should result in error", i, tc.fn)
+		} else if got := err.Error(); got != tc.msg {
+			t.Errorf("%d: %T should result in error %q, but got %q", i, tc.fn, tc.msg, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// NewUninternedSymbol creates a new symbol, that is not stored in any symbol
+// table. An uninterned symbol is only equal to itself, even if another symbol
+// has the same name. It is printed as "#:" followed by its name.
+func NewUninternedSymbol(name string) *Symbol {
+	return &Symbol{val: name, uninterned: true}
+}
+
+// IsUninterned returns true, if the symbol was created as an uninterned
+// symbol.
+func (sym *Symbol) IsUninterned() bool { return sym != nil && sym.uninterned }
+
+// Gensym creates uninterned symbols with unique names. The name consists of
+// a prefix and the value of a counter. It may be used by multiple goroutines
+// concurrently.
+type Gensym struct {
+	prefix  string
+	counter uint64
+}
+
+// NewGensym creates a new Gensym with the given default prefix. The first
+// created symbol gets the counter value start.
+func NewGensym(prefix string, start uint64) *Gensym {
+	return &Gensym{prefix: prefix, counter: start}
+}
+
+// Next returns a new uninterned symbol with the default prefix.
+func (g *Gensym) Next() *Symbol { return g.Make("") }
+
+// Make returns a new uninterned symbol with the given prefix. If the prefix
+// is empty, the default prefix is used.
+func (g *Gensym) Make(prefix string) *Symbol {
+	if prefix == "" {
+		prefix = g.prefix
+	}
+	n := atomic.AddUint64(&g.counter, 1) - 1
+	return NewUninternedSymbol(prefix + strconv.FormatUint(n, 10))
+}
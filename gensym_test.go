@@ -0,0 +1,74 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestUninternedSymbol(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	sym := sxpf.NewUninternedSymbol("G1")
+	if !sym.Equal(sym) {
+		t.Error("uninterned symbol must be equal to itself")
+	}
+	if sym.Equal(smk.MakeSymbol("G1")) || smk.MakeSymbol("G1").Equal(sym) {
+		t.Error("uninterned symbol must not be equal to interned symbol")
+	}
+	if sym.Equal(sxpf.NewUninternedSymbol("G1")) {
+		t.Error("uninterned symbols must not be equal")
+	}
+	if got := sym.String(); got != "#:G1" {
+		t.Errorf("uninterned symbol should be printed as #:G1, but got %q", got)
+	}
+
+	val, err := sxpf.ParseString(smk, "(#:g1 #:g1 #:g2 g1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := val.String(); got != "(#:g1 #:g1 #:g2 G1)" {
+		t.Errorf("unexpected parse result %v", got)
+	}
+	elems := val.(*sxpf.Pair).GetSlice()
+	if elems[0] != elems[1] {
+		t.Error("same uninterned name in one parse must result in the same symbol")
+	}
+	if elems[0].Equal(elems[2]) || elems[0].Equal(elems[3]) {
+		t.Error("uninterned symbol must only be equal to itself")
+	}
+}
+
+func TestGensym(t *testing.T) {
+	g := sxpf.NewGensym("tmp", 7)
+	if got := g.Next().String(); got != "#:tmp7" {
+		t.Errorf("expected #:tmp7, but got %v", got)
+	}
+	if got := g.Make("x").String(); got != "#:x8" {
+		t.Errorf("expected #:x8, but got %v", got)
+	}
+
+	engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+	engine.BindBuiltin(listForm)
+	engine.SetGensym(sxpf.NewGensym("T", 100))
+	expr, err := sxpf.ParseString(engine, "(BEGIN (DEFINE a (GENSYM)) (DEFINE b (GENSYM (QUOTE x))) (LIST a b))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := engine.Eval(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := val.String(); got != "(#:T100 #:X101)" {
+		t.Errorf("expected (#:T100 #:X101), but got %v", got)
+	}
+}
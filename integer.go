@@ -0,0 +1,62 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import "strconv"
+
+// Integer is a signed integer number with 64 bits.
+type Integer struct {
+	val int64
+}
+
+// NewInteger creates a new integer with the given value.
+func NewInteger(intVal int64) *Integer { return &Integer{intVal} }
+
+// ParseInteger returns the integer value of the given decimal representation.
+func ParseInteger(s string) (*Integer, bool) {
+	if s == "" {
+		return nil, false
+	}
+	i := 0
+	if s[0] == '+' || s[0] == '-' {
+		i++
+	}
+	if i == len(s) {
+		return nil, false
+	}
+	for ; i < len(s); i++ {
+		if s[i] < '0' || '9' < s[i] {
+			return nil, false
+		}
+	}
+	intVal, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return NewInteger(intVal), true
+}
+
+// GetValue returns the integer value.
+func (i *Integer) GetValue() int64 { return i.val }
+
+// Equal retruns true if the other value is equal to this one.
+func (i *Integer) Equal(other Value) bool {
+	if i == nil || other == nil {
+		return i == other
+	}
+	if o, ok := other.(*Integer); ok {
+		return i.val == o.val
+	}
+	return false
+}
+
+func (i *Integer) String() string { return strconv.FormatInt(i.val, 10) }
+func (i *Integer) Value() string  { return i.String() }
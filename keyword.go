@@ -0,0 +1,143 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keyword is a self-evaluating value, written as a name preceded by a colon,
+// e.g. ":indent". Keywords name options, most notably keyword arguments of
+// builtins (see Builtin.WithKeywords).
+//
+// Keywords are not interned: keywords with the same name are equal, but not
+// necessarily identical. They are independent of symbols and namespaces. A
+// parser applies the case policy of its SymbolMaker to the name of a
+// keyword, but it does not create a symbol.
+type Keyword struct {
+	name      string
+	sensitive bool // true, if case is significant for Equal
+}
+
+// MakeKeyword returns a keyword with the given name. The name must not
+// contain the leading colon. Like symbols of a SymbolTable with policy
+// CaseFolding, names are converted to upper case.
+func MakeKeyword(name string) *Keyword {
+	if name == "" {
+		return nil
+	}
+	return &Keyword{name: strings.ToUpper(name)}
+}
+
+// newKeyword returns a keyword with the given name, according to the case
+// policy.
+func newKeyword(name string, policy CasePolicy) *Keyword {
+	switch policy {
+	case CaseFolding:
+		return &Keyword{name: strings.ToUpper(name)}
+	case CaseSensitive:
+		return &Keyword{name: name, sensitive: true}
+	}
+	return &Keyword{name: name}
+}
+
+// GetValue returns the name of the keyword, without the leading colon.
+func (kw *Keyword) GetValue() string { return kw.name }
+
+// Equal retruns true if the other value is equal to this one.
+func (kw *Keyword) Equal(other Value) bool {
+	if kw == nil || other == nil {
+		return kw == other
+	}
+	if o, ok := other.(*Keyword); ok {
+		if kw.sensitive || o.sensitive {
+			return kw.name == o.name
+		}
+		return strings.EqualFold(kw.name, o.name)
+	}
+	return false
+}
+
+func (kw *Keyword) String() string { return ":" + kw.name }
+func (kw *Keyword) Value() string  { return kw.String() }
+
+// KeywordParam declares a keyword parameter of a builtin, together with the
+// value it gets, if the keyword argument is not given.
+type KeywordParam struct {
+	Key     *Keyword
+	Default Value
+}
+
+// WithKeywords returns a copy of the builtin, that accepts the given keyword
+// parameters.
+//
+// A call of the builtin consists of the positional arguments, followed by
+// pairs of a keyword and its value. The keyword arguments start with the
+// first keyword after the minimum number of positional arguments. Unknown
+// or duplicate keywords result in an error. The function of the builtin
+// receives the positional arguments, followed by one value for every
+// keyword parameter, in the order of declaration.
+func (b *Builtin) WithKeywords(params ...KeywordParam) *Builtin {
+	result := *b
+	result.keywords = params
+	return &result
+}
+
+// parseKeywordArgs returns the positional arguments, followed by the values
+// of all keyword parameters.
+func (b *Builtin) parseKeywordArgs(args []Value) ([]Value, int, error) {
+	pos := len(args)
+	for i := b.minArity; i < len(args); i++ {
+		if _, ok := args[i].(*Keyword); ok {
+			pos = i
+			break
+		}
+	}
+	result := make([]Value, pos, pos+len(b.keywords))
+	copy(result, args)
+	for _, param := range b.keywords {
+		if param.Default == nil {
+			result = append(result, Nil())
+		} else {
+			result = append(result, param.Default)
+		}
+	}
+	seen := make([]bool, len(b.keywords))
+	for i := pos; i < len(args); i += 2 {
+		kw, ok := args[i].(*Keyword)
+		if !ok {
+			return nil, 0, fmt.Errorf("%v is not a keyword for form %v", args[i], b.name)
+		}
+		idx := b.keywordIndex(kw)
+		if idx < 0 {
+			return nil, 0, fmt.Errorf("unknown keyword %v for form %v", kw, b.name)
+		}
+		if seen[idx] {
+			return nil, 0, fmt.Errorf("duplicate keyword %v for form %v", kw, b.name)
+		}
+		if i+1 >= len(args) {
+			return nil, 0, fmt.Errorf("missing value for keyword %v of form %v", kw, b.name)
+		}
+		seen[idx] = true
+		result[pos+idx] = args[i+1]
+	}
+	return result, pos, nil
+}
+
+func (b *Builtin) keywordIndex(kw *Keyword) int {
+	for i, param := range b.keywords {
+		if param.Key.Equal(kw) {
+			return i
+		}
+	}
+	return -1
+}
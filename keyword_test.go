@@ -0,0 +1,116 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestKeyword(t *testing.T) {
+	if !sxpf.MakeKeyword("a").Equal(sxpf.MakeKeyword("A")) {
+		t.Error("keywords with the same name must be equal")
+	}
+	engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+	if kw := sxpf.MakeKeyword("a"); kw.Equal(engine.MakeSymbol("A")) || kw.Equal(engine.MakeSymbol(":A")) {
+		t.Error("keyword must not be equal to a symbol")
+	}
+}
+
+func TestKeywordParse(t *testing.T) {
+	testcases := []struct {
+		smk   sxpf.SymbolMaker
+		src   string
+		exp   string
+		equal bool // equal to MakeKeyword("indent")
+	}{
+		{sxpf.NewTrivialSymbolMaker(), ":indent", ":INDENT", true},
+		{sxpf.NewSymbolMaker(sxpf.CasePreserving), ":indent", ":indent", true},
+		{sxpf.NewSymbolMaker(sxpf.CaseSensitive), ":indent", ":indent", false},
+		{sxpf.NewSymbolMaker(sxpf.CaseSensitive), ":INDENT", ":INDENT", true},
+	}
+	for i, tc := range testcases {
+		val, err := sxpf.ParseString(tc.smk, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should be parsed as %v, but got %v", i, tc.src, tc.exp, got)
+		}
+		if got := val.Equal(sxpf.MakeKeyword("indent")); got != tc.equal {
+			t.Errorf("%d: %v equal to :INDENT should be %v", i, tc.src, tc.equal)
+		}
+	}
+
+	ts := sxpf.NewTransientSymbols(sxpf.NewTrivialSymbolMaker(), 1)
+	if _, err := sxpf.ParseString(ts, "(:a :b :c x)"); err != nil || ts.Count() != 1 {
+		t.Errorf("keywords should not create symbols, but got %v / %d", err, ts.Count())
+	}
+
+	nss := sxpf.NewNamespaces(sxpf.CaseFolding)
+	nss.SetCurrent(nss.Namespace("lib"))
+	val, err := sxpf.ParseString(nss, ":indent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := val.String(); got != ":INDENT" || !val.Equal(sxpf.MakeKeyword("indent")) {
+		t.Errorf(":indent in namespace LIB should be :INDENT, but got %v", got)
+	}
+}
+
+func TestKeywordArgs(t *testing.T) {
+	format := sxpf.NewBuiltin(
+		"FORMAT",
+		false, 1, -1,
+		func(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+			return sxpf.NewPairFromSlice(args), nil
+		},
+	).WithKeywords(
+		sxpf.KeywordParam{Key: sxpf.MakeKeyword("indent"), Default: sxpf.NewInteger(0)},
+		sxpf.KeywordParam{Key: sxpf.MakeKeyword("sep")},
+	)
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(FORMAT 1)", "(1 0 ())"},
+		{"(FORMAT 1 2)", "(1 2 0 ())"},
+		{`(FORMAT 1 :sep "-")`, `(1 0 "-")`},
+		{`(FORMAT 1 2 :sep "-" :indent 4)`, `(1 2 4 "-")`},
+		{`(FORMAT :indent :sep 3)`, `(:INDENT 0 3)`},
+		{"(FORMAT)", "not enough arguments (0) for form FORMAT (1)"},
+		{"(FORMAT 1 :width 3)", "unknown keyword :WIDTH for form FORMAT"},
+		{"(FORMAT 1 :sep 3 :sep 4)", "duplicate keyword :SEP for form FORMAT"},
+		{"(FORMAT 1 :sep)", "missing value for keyword :SEP of form FORMAT"},
+		{"(FORMAT 1 :sep 3 4)", "4 is not a keyword for form FORMAT"},
+	}
+	for i, tc := range testcases {
+		engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+		engine.BindBuiltin(format)
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		var got string
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = val.String()
+		}
+		if got != tc.exp {
+			t.Errorf("%d: %v should result in %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"context"
+	"fmt"
+)
+
+// Allocator is implemented by environments that restrict the memory used
+// for an evaluation.
+type Allocator interface {
+	// Allocate announces the allocation of approximately n values. It
+	// returns an error, if the allocation exceeds a limit.
+	Allocate(n int) error
+}
+
+// Allocate announces the allocation of approximately n values to the given
+// environment, if it implements Allocator. Builtins that create big values
+// should call it before.
+func Allocate(env Environment, n int) error {
+	if a, ok := env.(Allocator); ok {
+		return a.Allocate(n)
+	}
+	return nil
+}
+
+// Allocate announces the allocation of approximately n values.
+func (e *Engine) Allocate(n int) error { return e.state.allocate(n) }
+
+// SetMaxDepth sets the maximum number of nested form calls and returns the
+// previous value. Calls in tail position do not count.
+func (e *Engine) SetMaxDepth(n uint) uint {
+	prevN := e.state.maxDepth
+	e.state.maxDepth = n
+	return prevN
+}
+
+// SetMaxSteps sets the maximum number of evaluation steps and returns the
+// previous value. Every evaluation of a symbol, a string, a list, or a vector
+// is a step. A value of zero means no limit.
+func (e *Engine) SetMaxSteps(n uint64) uint64 {
+	prevN := e.state.maxSteps
+	e.state.maxSteps = n
+	return prevN
+}
+
+// SetMaxAlloc sets the maximum number of values that may be allocated and
+// returns the previous value. The number is an approximation: calls count
+// their arguments, new scopes count their bindings, and builtins may count
+// the values they create (see Allocate). A value of zero means no limit.
+func (e *Engine) SetMaxAlloc(n uint64) uint64 {
+	prevN := e.state.maxAlloc
+	e.state.maxAlloc = n
+	return prevN
+}
+
+// start begins a top-level evaluation with the given context: the counters of
+// steps and allocations are reset. It returns a function that ends the
+// evaluation. If an evaluation is already active, it continues unchanged.
+func (st *engineState) start(ctx context.Context) (end func()) {
+	if st.ctx != nil {
+		return func() {}
+	}
+	st.ctx, st.steps, st.alloc = ctx, 0, 0
+	return func() { st.ctx = nil }
+}
+
+func (st *engineState) step() error {
+	st.steps++
+	if st.maxSteps > 0 && st.steps > st.maxSteps {
+		return &MaxStepsError{st.maxSteps}
+	}
+	if st.ctx != nil {
+		select {
+		case <-st.ctx.Done():
+			return &CanceledError{st.ctx.Err()}
+		default:
+		}
+	}
+	return nil
+}
+
+func (st *engineState) allocate(n int) error {
+	if n > 0 {
+		st.alloc += uint64(n)
+	}
+	if st.maxAlloc > 0 && st.alloc > st.maxAlloc {
+		return &MaxAllocError{st.maxAlloc}
+	}
+	return nil
+}
+
+// MaxDepthError is returned as an error, if form calls are nested too deeply.
+type MaxDepthError struct {
+	MaxDepth uint
+}
+
+func (e *MaxDepthError) Error() string {
+	return fmt.Sprintf("maximum call depth %d exceeded", e.MaxDepth)
+}
+
+// MaxStepsError is returned as an error, if an evaluation needs too many
+// steps.
+type MaxStepsError struct {
+	MaxSteps uint64
+}
+
+func (e *MaxStepsError) Error() string {
+	return fmt.Sprintf("maximum number of evaluation steps %d exceeded", e.MaxSteps)
+}
+
+// MaxAllocError is returned as an error, if an evaluation allocates too many
+// values.
+type MaxAllocError struct {
+	MaxAlloc uint64
+}
+
+func (e *MaxAllocError) Error() string {
+	return fmt.Sprintf("maximum number of allocated values %d exceeded", e.MaxAlloc)
+}
+
+// CanceledError is returned as an error, if the context of an evaluation is
+// done.
+type CanceledError struct {
+	Err error // the error of the context
+}
+
+func (e *CanceledError) Error() string { return "evaluation canceled: " + e.Err.Error() }
+func (e *CanceledError) Unwrap() error { return e.Err }
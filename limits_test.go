@@ -0,0 +1,103 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/t73fde/sxpf"
+)
+
+func evalLimited(t *testing.T, ctx context.Context, engine *sxpf.Engine, src string) error {
+	t.Helper()
+	expr, err := sxpf.ParseString(engine, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := engine.EvalContext(ctx, expr)
+	if err == nil {
+		t.Fatalf("%v should result in error, but got: %v", src, val)
+	}
+	return err
+}
+
+const srcLoop = "(BEGIN (DEFINE (loop x) (loop (LIST x x))) (loop ()))"
+
+func TestMaxSteps(t *testing.T) {
+	engine := newMacroTestEngine()
+	engine.SetMaxSteps(1000)
+	err := evalLimited(t, context.Background(), engine, srcLoop)
+	var mse *sxpf.MaxStepsError
+	if !errors.As(err, &mse) || mse.MaxSteps != 1000 {
+		t.Errorf("MaxStepsError with limit 1000 expected, but got %v", err)
+	}
+
+	// Steps are counted for each evaluation
+	expr, err := sxpf.ParseString(engine, "(LIST (QUOTE a))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = engine.Eval(expr); err != nil {
+		t.Errorf("steps are not reset: %v", err)
+	}
+}
+
+func TestMaxAlloc(t *testing.T) {
+	engine := newMacroTestEngine()
+	engine.SetMaxAlloc(500)
+	err := evalLimited(t, context.Background(), engine, srcLoop)
+	var mae *sxpf.MaxAllocError
+	if !errors.As(err, &mae) || mae.MaxAlloc != 500 {
+		t.Errorf("MaxAllocError with limit 500 expected, but got %v", err)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	engine := newMacroTestEngine()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := evalLimited(t, ctx, engine, srcLoop)
+	var ce *sxpf.CanceledError
+	if !errors.As(err, &ce) {
+		t.Errorf("CanceledError expected, but got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v should wrap %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestLimitsRepeated(t *testing.T) {
+	engine := newMacroTestEngine()
+	engine.SetMaxSteps(20)
+	engine.SetMaxAlloc(20)
+	expr, err := sxpf.ParseString(engine, "(LIST (LIST (QUOTE a) (QUOTE b)) (QUOTE c))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := engine.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err = engine.Eval(expr); err != nil {
+			t.Fatalf("%d: Eval failed: %v", i, err)
+		}
+		if _, err = sxpf.Evaluate(engine, expr); err != nil {
+			t.Fatalf("%d: Evaluate failed: %v", i, err)
+		}
+		if _, err = code.Run(); err != nil {
+			t.Fatalf("%d: Run failed: %v", i, err)
+		}
+	}
+}
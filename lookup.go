@@ -10,31 +10,292 @@
 
 package sxpf
 
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
 // SymbolMap maps symbols to values.
+//
+// A SymbolMap may be used by multiple goroutines concurrently. Reading and
+// writing the same symbol map is serialized by a read-write lock.
 type SymbolMap struct {
 	parent *SymbolMap
+	mx     sync.RWMutex
 	assoc  map[*Symbol]Value
+	shared bool // assoc is shared with a snapshot and must be copied before writing
+
+	observed uint32  // bindings of this map are cached by a bindingCell
+	gen      *uint64 // generation of the bindings, shared with the root map
 }
 
 func NewSymbolMap(parentMap *SymbolMap) *SymbolMap {
 	return &SymbolMap{
 		parent: parentMap,
 		assoc:  map[*Symbol]Value{},
+		gen:    parentMap.rootGen(),
+	}
+}
+
+// rootGen returns the binding generation of the root map. A nil map is the
+// parent of a new root.
+func (sm *SymbolMap) rootGen() *uint64 {
+	if sm == nil {
+		return new(uint64)
 	}
+	return sm.gen
 }
 
-// Set a symbol to its associated value.
+// Set a symbol to its associated value in this map, i.e. define it locally.
+// A binding of the symbol in a parent map is shadowed.
 func (sm *SymbolMap) Set(sym *Symbol, val Value) {
-	sm.assoc[sym] = val
+	sm.mx.Lock()
+	key := sm.keyOf(sym)
+	sm.prepareWrite()
+	_, found := sm.assoc[key]
+	sm.assoc[key] = val
+	sm.mx.Unlock()
+	if !found {
+		sm.changed()
+	}
+}
+
+// Update changes the value of the symbol in the map of the parent chain,
+// that binds the symbol. It returns false, if the symbol is not bound.
+func (sm *SymbolMap) Update(sym *Symbol, val Value) bool {
+	bsm, bsym := sm.lookupBinding(sym)
+	if bsm == nil {
+		return false
+	}
+	bsm.Set(bsym, val)
+	return true
+}
+
+// Delete removes the binding of the symbol from this map, ignoring the
+// parent map. It returns true, if the symbol was bound in this map.
+func (sm *SymbolMap) Delete(sym *Symbol) bool {
+	sm.mx.Lock()
+	defer sm.mx.Unlock()
+	key := sm.keyOf(sym)
+	if _, found := sm.assoc[key]; !found {
+		return false
+	}
+	sm.prepareWrite()
+	delete(sm.assoc, key)
+	sm.changed()
+	return true
+}
+
+// keyOf returns the symbol, that binds a value to the given symbol in this
+// map. A symbol, that was bound before it was promoted, keeps its binding.
+// The caller must hold a lock.
+func (sm *SymbolMap) keyOf(sym *Symbol) *Symbol {
+	if former := sym.formerKey(); former != nil {
+		if _, found := sm.assoc[former]; found {
+			return former
+		}
+	}
+	return sym.key()
+}
+
+// prepareWrite copies the associations, if they are shared with a snapshot.
+// The caller must hold the write lock.
+func (sm *SymbolMap) prepareWrite() {
+	if sm.shared {
+		assoc := make(map[*Symbol]Value, len(sm.assoc))
+		for sym, val := range sm.assoc {
+			assoc[sym] = val
+		}
+		sm.assoc, sm.shared = assoc, false
+	}
+}
+
+// Snapshot returns a copy of the symbol map and its parent maps. Later
+// changes of the symbol map do not change the snapshot, and vice versa. The
+// bindings are copied lazily, when one of the maps is changed.
+func (sm *SymbolMap) Snapshot() *SymbolMap {
+	if sm == nil {
+		return nil
+	}
+	parent := sm.parent.Snapshot()
+	sm.mx.Lock()
+	defer sm.mx.Unlock()
+	sm.shared = true
+	return &SymbolMap{parent: parent, assoc: sm.assoc, shared: true, gen: parent.rootGen()}
+}
+
+// Parent returns the parent map, or nil if there is none.
+func (sm *SymbolMap) Parent() *SymbolMap { return sm.parent }
+
+// Symbols returns the symbols bound in this map, ignoring the parent map.
+// They are sorted by their string representation.
+func (sm *SymbolMap) Symbols() []*Symbol {
+	sm.mx.RLock()
+	result := make([]*Symbol, 0, len(sm.assoc))
+	for sym := range sm.assoc {
+		result = append(result, sym)
+	}
+	sm.mx.RUnlock()
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
+
+// get returns the value associated with the symbol in this map, ignoring
+// the parent map.
+func (sm *SymbolMap) get(sym *Symbol) (Value, bool) {
+	sm.mx.RLock()
+	val, found := sm.assoc[sm.keyOf(sym)]
+	sm.mx.RUnlock()
+	return val, found
+}
+
+// entries returns a copy of all associations of this map, ignoring the
+// parent map.
+func (sm *SymbolMap) entries() map[*Symbol]Value {
+	sm.mx.RLock()
+	defer sm.mx.RUnlock()
+	result := make(map[*Symbol]Value, len(sm.assoc))
+	for sym, val := range sm.assoc {
+		result[sym] = val
+	}
+	return result
 }
 
 // Lookup the value assiated with a given symbol.
+//
+// If the symbol was renamed by a macro expansion and is not bound, the
+// original symbol is looked up in the scope of the macro definition. If the
+// symbol belongs to a namespace and is not bound, the symbols of the same
+// name exported by the imported namespaces are looked up.
 func (sm *SymbolMap) Lookup(sym *Symbol) (Value, bool) {
+	if val, found := sm.lookupScope(sym); found {
+		return val, true
+	}
+	for _, isym := range sym.importedSymbols() {
+		if val, found := sm.lookupScope(isym); found {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+func (sm *SymbolMap) lookupScope(sym *Symbol) (Value, bool) {
 	for curSm := sm; curSm != nil; curSm = curSm.parent {
-		if val, found := curSm.assoc[sym]; found {
+		if val, found := curSm.get(sym); found {
 			return val, true
 		}
 	}
+	if o := sym.getOrigin(); o != nil {
+		return o.scope.Lookup(o.sym)
+	}
+	return nil, false
+}
+
+// lookupBinding returns the symbol map in the parent chain that binds the
+// given symbol, or nil if the symbol is not bound. Since the symbol might be
+// renamed by a macro expansion or imported from another namespace, the
+// actual bound symbol is returned too.
+func (sm *SymbolMap) lookupBinding(sym *Symbol) (*SymbolMap, *Symbol) {
+	if bsm, bsym := sm.lookupScopeBinding(sym); bsm != nil {
+		return bsm, bsym
+	}
+	for _, isym := range sym.importedSymbols() {
+		if bsm, bsym := sm.lookupScopeBinding(isym); bsm != nil {
+			return bsm, bsym
+		}
+	}
+	return nil, nil
+}
+
+func (sm *SymbolMap) lookupScopeBinding(sym *Symbol) (*SymbolMap, *Symbol) {
+	for curSm := sm; curSm != nil; curSm = curSm.parent {
+		if _, found := curSm.get(sym); found {
+			return curSm, sym
+		}
+	}
+	if o := sym.getOrigin(); o != nil {
+		return o.scope.lookupBinding(o.sym)
+	}
+	return nil, nil
+}
+
+// sharedGen is incremented, whenever the imports or exports of a namespace
+// change, or a symbol becomes bound or unbound in a map, that is observed
+// through the origin of a renamed symbol by a cell of another root map.
+var sharedGen uint64
+
+// Flags of SymbolMap.observed.
+const (
+	observedByRoot    = 1 // by a cell that starts at a map of the same root
+	observedByForeign = 2 // by a cell that starts at a map of another root
+)
+
+// bindingCell caches the symbol map that binds a symbol, starting the lookup
+// at a given map. It avoids walking the parent chain on every lookup. A cell
+// is valid, as long as neither the generation of the root map of its start
+// map nor sharedGen changes. A cell must be used by one goroutine at a time.
+//
+// Only compiled code uses binding cells. The interpreter looks up every
+// symbol without caching.
+type bindingCell struct {
+	gen    uint64
+	shared uint64
+	start  *SymbolMap
+	owner  *SymbolMap
+	sym    *Symbol // the bound symbol
+}
+
+// changed invalidates the binding cells, that observe the map.
+func (sm *SymbolMap) changed() {
+	observed := atomic.LoadUint32(&sm.observed)
+	if observed&observedByRoot != 0 {
+		atomic.AddUint64(sm.gen, 1)
+	}
+	if observed&observedByForeign != 0 {
+		atomic.AddUint64(&sharedGen, 1)
+	}
+}
+
+// observe marks all maps that are consulted when the symbol is looked up.
+func (sm *SymbolMap) observe(sym *Symbol, flag uint32) {
+	for curSm := sm; curSm != nil; curSm = curSm.parent {
+		for {
+			observed := atomic.LoadUint32(&curSm.observed)
+			if observed&flag != 0 || atomic.CompareAndSwapUint32(&curSm.observed, observed, observed|flag) {
+				break
+			}
+		}
+	}
+	if o := sym.getOrigin(); o != nil {
+		if o.scope.gen != sm.gen {
+			flag = observedByForeign
+		}
+		o.scope.observe(o.sym, flag)
+	}
+}
+
+// lookupCell is like lookupBinding, but uses and updates the given cell.
+func (sm *SymbolMap) lookupCell(c *bindingCell, sym *Symbol) (*SymbolMap, *Symbol) {
+	if c.start == sm && c.gen == atomic.LoadUint64(sm.gen) && c.shared == atomic.LoadUint64(&sharedGen) {
+		return c.owner, c.sym
+	}
+	sm.observe(sym, observedByRoot)
+	gen, shared := atomic.LoadUint64(sm.gen), atomic.LoadUint64(&sharedGen)
+	bsm, bsym := sm.lookupBinding(sym)
+	if bsm == nil {
+		*c = bindingCell{}
+		return nil, nil
+	}
+	*c = bindingCell{gen: gen, shared: shared, start: sm, owner: bsm, sym: bsym}
+	return bsm, bsym
+}
+
+// lookupCached is like Lookup, but uses and updates the given cell.
+func (sm *SymbolMap) lookupCached(c *bindingCell, sym *Symbol) (Value, bool) {
+	if bsm, bsym := sm.lookupCell(c, sym); bsm != nil {
+		return bsm.get(bsym)
+	}
 	return nil, false
 }
 
@@ -49,7 +310,8 @@ func (sm *SymbolMap) LookupForm(sym *Symbol) (Form, error) {
 	return nil, ErrNotFormBound(sym)
 }
 
-// AsVector returns a vector representation of the symbol map.
+// AsVector returns a vector representation of the symbol map. The bindings
+// are sorted by the string representation of their symbols.
 func (sm *SymbolMap) AsVector() *Vector {
 	if sm == nil {
 		return Empty()
@@ -62,8 +324,10 @@ func (sm *SymbolMap) AsVector() *Vector {
 		parent.Append(sm.parent.AsVector())
 	}
 	result.Append(parent)
-	for sym, val := range sm.assoc {
-		result.Append(NewVector(sym, val))
+	for _, sym := range sm.Symbols() {
+		if val, found := sm.get(sym); found {
+			result.Append(NewVector(sym, val))
+		}
 	}
 	return result
 }
@@ -81,11 +345,15 @@ func (sm *SymbolMap) Equal(other Value) bool {
 	if sm == o {
 		return true
 	}
-	if !sm.parent.Equal(o.parent) || len(sm.assoc) != len(o.assoc) {
+	if !sm.parent.Equal(o.parent) {
 		return false
 	}
-	for sym, val := range sm.assoc {
-		if oval, found := o.assoc[sym]; !found || !val.Equal(oval) {
+	assoc, oassoc := sm.entries(), o.entries()
+	if len(assoc) != len(oassoc) {
+		return false
+	}
+	for sym, val := range assoc {
+		if oval, found := oassoc[sym]; !found || !val.Equal(oval) {
 			return false
 		}
 	}
@@ -11,6 +11,8 @@
 package sxpf_test
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/t73fde/sxpf"
@@ -46,3 +48,99 @@ func TestSymbolMapPrint(t *testing.T) {
 		t.Errorf("sm2:\nexpected: %v,\n but got: %v", exp, got)
 	}
 }
+
+func TestSymbolMapConcurrent(t *testing.T) {
+	const numWorkers, numSymbols = 8, 100
+	smk := sxpf.NewTrivialSymbolMaker()
+	global := sxpf.NewSymbolMap(nil)
+	shared := smk.MakeSymbol("shared")
+	global.Set(shared, sxpf.NewString("global"))
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			local := sxpf.NewSymbolMap(global)
+			for i := 0; i < numSymbols; i++ {
+				sym := smk.MakeSymbol(fmt.Sprintf("s%d", i))
+				global.Set(sym, sxpf.NewInteger(int64(i)))
+				local.Set(sym, sxpf.NewInteger(int64(w)))
+				if val, found := local.Lookup(sym); !found || !val.Equal(sxpf.NewInteger(int64(w))) {
+					t.Errorf("worker %d: local value of %v should be %d, but got %v", w, sym, w, val)
+				}
+				if val, found := local.Lookup(shared); !found || !val.Equal(sxpf.NewString("global")) {
+					t.Errorf("worker %d: value of %v should be found, but got %v", w, shared, val)
+				}
+				_ = global.String()
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestSymbolMapOrder(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	sm := sxpf.NewSymbolMap(nil)
+	for _, name := range []string{"c", "a", "d", "b"} {
+		sm.Set(smk.MakeSymbol(name), sxpf.NewString(name))
+	}
+	exp := `["symbol" ["parent" ()] [A "a"] [B "b"] [C "c"] [D "d"]]`
+	for i := 0; i < 10; i++ {
+		if got := sm.String(); got != exp {
+			t.Fatalf("expected: %v,\nbut got: %v", exp, got)
+		}
+	}
+}
+
+func TestSymbolMapModify(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	symA, symB := smk.MakeSymbol("a"), smk.MakeSymbol("b")
+	parent := sxpf.NewSymbolMap(nil)
+	parent.Set(symA, sxpf.NewInteger(1))
+	child := sxpf.NewSymbolMap(parent)
+
+	if child.Update(symB, sxpf.NewInteger(2)) {
+		t.Error("unbound symbol must not be updated")
+	}
+	if !child.Update(symA, sxpf.NewInteger(3)) || len(child.Symbols()) != 0 {
+		t.Error("bound symbol must be updated in the parent map")
+	}
+	if val, _ := parent.Lookup(symA); !val.Equal(sxpf.NewInteger(3)) {
+		t.Errorf("value of A should be 3, but got %v", val)
+	}
+
+	child.Set(symA, sxpf.NewInteger(4))
+	if got := fmt.Sprint(child.Symbols()); got != "[A]" {
+		t.Errorf("child should bind [A], but got %v", got)
+	}
+	if child.Delete(symB) || !child.Delete(symA) || child.Delete(symA) {
+		t.Error("binding must be deleted exactly once")
+	}
+	if val, _ := child.Lookup(symA); !val.Equal(sxpf.NewInteger(3)) {
+		t.Errorf("deleting must reveal the binding of the parent, but got %v", val)
+	}
+}
+
+func TestSymbolMapSnapshot(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	symA, symB := smk.MakeSymbol("a"), smk.MakeSymbol("b")
+	parent := sxpf.NewSymbolMap(nil)
+	parent.Set(symA, sxpf.NewInteger(1))
+	child := sxpf.NewSymbolMap(parent)
+	child.Set(symB, sxpf.NewInteger(2))
+
+	snap := child.Snapshot()
+	exp := snap.String()
+	if got := child.String(); got != exp {
+		t.Errorf("snapshot should be %v, but got %v", got, exp)
+	}
+	parent.Set(symA, sxpf.NewInteger(10))
+	child.Delete(symB)
+	if got := snap.String(); got != exp {
+		t.Errorf("snapshot must not change, expected %v, but got %v", exp, got)
+	}
+	snap.Set(symA, sxpf.NewInteger(5))
+	if val, _ := child.Lookup(symA); !val.Equal(sxpf.NewInteger(10)) {
+		t.Errorf("changing the snapshot must not change the map, but got %v", val)
+	}
+}
@@ -0,0 +1,329 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import "fmt"
+
+// Macro is a value that transforms a s-expression into another s-expression.
+// A macro call is replaced by its expansion before it is evaluated.
+type Macro struct {
+	name string
+	fn   BuiltinFn
+}
+
+// NewMacro creates a new macro. The given function receives the unevaluated
+// arguments of a macro call and returns the expansion.
+func NewMacro(name string, fn BuiltinFn) *Macro { return &Macro{name, fn} }
+
+// Name returns the name of the macro.
+func (m *Macro) Name() string {
+	if m == nil {
+		return ""
+	}
+	return m.name
+}
+
+func (m *Macro) Equal(other Value) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if o, ok := other.(*Macro); ok {
+		return m == o
+	}
+	return false
+}
+
+func (m *Macro) String() string {
+	if m.name == "" {
+		return "#<macro>"
+	}
+	return "#<macro " + m.name + ">"
+}
+
+// Expand a call of the macro with the given arguments.
+func (m *Macro) Expand(env Environment, args []Value) (Value, error) {
+	return resolveTailCall(m.fn(env, args))
+}
+
+// lookupMacro returns the macro and its arguments, if the given value is a
+// call of a macro.
+func (e *Engine) lookupMacro(val Value) (*Macro, []Value) {
+	var vals []Value
+	switch v := val.(type) {
+	case *Pair:
+		elems, tail := v.getElems()
+		if tail != nil {
+			return nil, nil
+		}
+		vals = elems
+	case *Vector:
+		vals = v.GetSlice()
+	}
+	if m := e.macroOf(vals); m != nil {
+		return m, vals[1:]
+	}
+	return nil, nil
+}
+
+// macroOf returns the macro, if the first value is a symbol bound to a macro.
+func (e *Engine) macroOf(vals []Value) *Macro {
+	if len(vals) == 0 {
+		return nil
+	}
+	if sym, ok := vals[0].(*Symbol); ok {
+		if bound, found := e.symMap.Lookup(sym); found {
+			if m, isMacro := bound.(*Macro); isMacro {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// MacroExpand1 expands the given value once, if it is a macro call. The
+// boolean result states whether the value was a macro call.
+func (e *Engine) MacroExpand1(val Value) (Value, bool, error) {
+	m, args := e.lookupMacro(val)
+	if m == nil {
+		return val, false, nil
+	}
+	res, err := m.Expand(e, args)
+	if err != nil {
+		return nil, true, err
+	}
+	return res, true, nil
+}
+
+// MacroExpand expands the given value, until it is not a macro call.
+// Sub-expressions are not expanded.
+func (e *Engine) MacroExpand(val Value) (Value, error) {
+	for {
+		res, expanded, err := e.MacroExpand1(val)
+		if err != nil || !expanded {
+			return res, err
+		}
+		val = res
+	}
+}
+
+// Expand the given value and all of its sub-expressions, until no macro call
+// is left. Quoted values are not expanded.
+func (e *Engine) Expand(val Value) (Value, error) {
+	val, err := e.MacroExpand(val)
+	if err != nil {
+		return nil, err
+	}
+	return e.expandSeq(val, e.expandCall)
+}
+
+// expandSeq applies the function to the elements of the given list or
+// vector. If no element was changed, the given value is returned.
+func (e *Engine) expandSeq(val Value, fn func([]Value) ([]Value, bool, error)) (Value, error) {
+	switch v := val.(type) {
+	case *Pair:
+		elems, tail := v.getElems()
+		if len(elems) == 0 || tail != nil {
+			return val, nil
+		}
+		res, changed, err := fn(elems)
+		if err != nil || !changed {
+			return val, err
+		}
+		return NewPairFromSlice(res), nil
+	case *Vector:
+		elems := v.GetSlice()
+		if len(elems) == 0 {
+			return val, nil
+		}
+		res, changed, err := fn(elems)
+		if err != nil || !changed {
+			return val, err
+		}
+		return NewVector(res...), nil
+	}
+	return val, nil
+}
+
+// expandSeqFrom expands all elements of the given list or vector, starting
+// with the given index.
+func (e *Engine) expandSeqFrom(val Value, from int) (Value, error) {
+	return e.expandSeq(val, func(elems []Value) ([]Value, bool, error) {
+		return expandEach(elems, from, e.Expand)
+	})
+}
+
+// expandEach applies the function to all values, starting with the given
+// index. The given slice is copied only if some value was changed.
+func expandEach(vals []Value, from int, fn func(Value) (Value, error)) ([]Value, bool, error) {
+	var res []Value
+	for i := from; i < len(vals); i++ {
+		exp, err := fn(vals[i])
+		if err != nil {
+			return nil, false, err
+		}
+		if res == nil {
+			if exp == vals[i] {
+				continue
+			}
+			res = make([]Value, len(vals))
+			copy(res, vals)
+		}
+		res[i] = exp
+	}
+	if res == nil {
+		return vals, false, nil
+	}
+	return res, true, nil
+}
+
+// expandCall expands the elements of a call, depending on the special form
+// that is called.
+func (e *Engine) expandCall(elems []Value) ([]Value, bool, error) {
+	switch e.specialFormName(elems[0]) {
+	case "QUOTE", "DEFINE-SYNTAX", "SYNTAX-RULES":
+		return elems, false, nil
+	case "LAMBDA", "DEFINE":
+		return expandEach(elems, 2, e.Expand)
+	case "DEFMACRO":
+		return expandEach(elems, 3, e.Expand)
+	case "COND":
+		return expandEach(elems, 1, func(clause Value) (Value, error) {
+			return e.expandSeqFrom(clause, 0)
+		})
+	case "LET":
+		return expandFirstAndRest(elems, func(bindings Value) (Value, error) {
+			return e.expandSeq(bindings, func(bs []Value) ([]Value, bool, error) {
+				return expandEach(bs, 0, func(binding Value) (Value, error) {
+					return e.expandSeqFrom(binding, 1)
+				})
+			})
+		}, e.Expand)
+	case "HANDLER-CASE":
+		return expandFirstAndRest(elems, e.Expand, func(clause Value) (Value, error) {
+			return e.expandSeqFrom(clause, 2)
+		})
+	}
+	return expandEach(elems, 0, e.Expand)
+}
+
+// expandFirstAndRest expands the first argument of a call with the function
+// first, and all other arguments with the function rest.
+func expandFirstAndRest(elems []Value, first, rest func(Value) (Value, error)) ([]Value, bool, error) {
+	res, changed, err := expandEach(elems, 2, rest)
+	if err != nil || len(elems) < 2 {
+		return res, changed, err
+	}
+	exp, err := first(elems[1])
+	if err != nil || exp == elems[1] {
+		return res, changed, err
+	}
+	if !changed {
+		res = make([]Value, len(elems))
+		copy(res, elems)
+	}
+	res[1] = exp
+	return res, true, nil
+}
+
+// specialFormName returns the name of the special form the given value is
+// bound to, or the empty string.
+func (e *Engine) specialFormName(val Value) string {
+	if sym, ok := val.(*Symbol); ok {
+		if bound, found := e.symMap.Lookup(sym); found {
+			if b, isBuiltin := bound.(*Builtin); isBuiltin && b.IsSpecial() {
+				return b.Name()
+			}
+		}
+	}
+	return ""
+}
+
+// (DEFMACRO name params body...) binds name to a macro. When called, the
+// body is evaluated with the unevaluated arguments bound to the parameters.
+// The result is the expansion.
+func defmacroFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := GetSymbol(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClosure(e, args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	c.name = sym.GetValue()
+	e.Define(sym, NewMacro(c.name, c.Call))
+	return sym, nil
+}
+
+// (DEFINE-SYNTAX name expr) binds name to the macro that is the result of
+// evaluating expr, typically a SYNTAX-RULES form.
+func defineSyntaxFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := GetSymbol(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	val, err := Evaluate(e, args[1])
+	if err != nil {
+		return nil, err
+	}
+	m, ok := val.(*Macro)
+	if !ok {
+		return nil, fmt.Errorf("DEFINE-SYNTAX value %v is not a macro", val)
+	}
+	if m.name == "" {
+		m.name = sym.GetValue()
+	}
+	e.Define(sym, m)
+	return sym, nil
+}
+
+// (GENSYM prefix?) returns a new uninterned symbol, that is different to all
+// other symbols.
+func gensymFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if len(args) > 0 {
+		if prefix, err = GetString(args, 0); err != nil {
+			return nil, err
+		}
+	}
+	return e.state.gensym.Make(prefix), nil
+}
+
+// (MACROEXPAND-1 value) expands value once, if it is a macro call.
+func macroExpand1Fn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := e.MacroExpand1(args[0])
+	return res, err
+}
+
+// (MACROEXPAND value) expands value, until it is not a macro call.
+func macroExpandFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	return e.MacroExpand(args[0])
+}
@@ -0,0 +1,164 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+var listForm = sxpf.NewBuiltin(
+	"LIST",
+	false, 0, -1,
+	func(_ sxpf.Environment, args []sxpf.Value) (sxpf.Value, error) {
+		return sxpf.NewPairFromSlice(args), nil
+	},
+)
+
+func newMacroTestEngine() *sxpf.Engine {
+	engine := newTestEngine()
+	engine.BindBuiltin(listForm)
+	return engine
+}
+
+const (
+	srcUnless = "(DEFMACRO unless (c x) (LIST (QUOTE IF) c () x))"
+	srcSwap   = "(DEFINE-SYNTAX swap! (SYNTAX-RULES () ((_ a b) (LET ((tmp a)) (SET! a b) (SET! b tmp)))))"
+	srcLetStr = "(DEFINE-SYNTAX let* (SYNTAX-RULES () ((_ () body ...) (BEGIN body ...)) ((_ ((n v) rest ...) body ...) (LET ((n v)) (let* (rest ...) body ...)))))"
+)
+
+func TestMacro(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(BEGIN " + srcUnless + " (unless () (QUOTE a)))", "A"},
+		{"(BEGIN " + srcUnless + " (unless (QUOTE x) (QUOTE a)))", "()"},
+		{"(BEGIN " + srcUnless + " (MACROEXPAND-1 (QUOTE (unless () (QUOTE a)))))", "(IF () () (QUOTE A))"},
+		{"(BEGIN " + srcUnless + " (MACROEXPAND (QUOTE (x))))", "(X)"},
+		{"(BEGIN " + srcUnless + " unless)", "#<macro UNLESS>"},
+		{"(BEGIN (DEFMACRO or2 (a b) (LET ((g (GENSYM))) (LIST (QUOTE LET) (LIST (LIST g a)) (LIST (QUOTE IF) g g b))))" +
+			" (LET ((g (QUOTE x))) (or2 () g)))", "X"},
+		{"(BEGIN " + srcSwap + " (DEFINE tmp (QUOTE x)) (DEFINE y (QUOTE y)) (swap! tmp y) (CAT tmp y))", `"YX"`},
+		{"(BEGIN " + srcSwap + " (LET ((tmp (QUOTE x)) (y (QUOTE y))) (swap! y tmp) (CAT tmp y)))", `"YX"`},
+		{"(BEGIN " + srcSwap + " swap!)", "#<macro SWAP!>"},
+		{"(BEGIN (DEFINE-SYNTAX my-list (SYNTAX-RULES () ((_ x ...) (LIST x ...)))) (my-list (QUOTE a) (QUOTE b)))", "(A B)"},
+		{"(BEGIN (DEFINE-SYNTAX my-list (SYNTAX-RULES () ((_ x ...) (LIST x ...)))) (my-list))", "()"},
+		{"(BEGIN (DEFINE-SYNTAX rev (SYNTAX-RULES () ((_ x ... y) (LIST y x ...)))) (rev (QUOTE a) (QUOTE b) (QUOTE c)))", "(C A B)"},
+		{"(BEGIN (DEFINE-SYNTAX tl (SYNTAX-RULES () ((_ x . y) (QUOTE y)))) (tl a b c))", "(B C)"},
+		{"(BEGIN (DEFINE-SYNTAX vec (SYNTAX-RULES () ((_ [x y]) (CAT y x)))) (vec [(QUOTE a) (QUOTE b)]))", `"BA"`},
+		{"(BEGIN (DEFINE-SYNTAX arrow (SYNTAX-RULES (=>) ((_ a => b) (CAT a b)) ((_ a b) (CAT b a))))" +
+			" (CAT (arrow (QUOTE a) => (QUOTE b)) (arrow (QUOTE a) (QUOTE b))))", `"\"AB\"\"BA\""`},
+		{"(BEGIN " + srcLetStr + " (let* ((a (QUOTE x)) (b (CAT a (QUOTE y)))) (CAT b a)))", `"\"XY\"X"`},
+		{"(BEGIN (DEFINE-SYNTAX pairs (SYNTAX-RULES () ((_ (a b ...) ...) (QUOTE ((b ... a) ...))))) (pairs (x 1 2) (y) (z 3)))",
+			"((1 2 X) (Y) (3 Z))"},
+		{"(BEGIN (DEFINE-SYNTAX my-if (SYNTAX-RULES () ((_ c a b) (COND (c a) (ELSE b))))) (my-if () (QUOTE a) (QUOTE b)))", "B"},
+		{"(BEGIN (DEFINE-SYNTAX twice (SYNTAX-RULES () ((_ x) (helper x x)))) (DEFINE (helper a b) (CAT a b)) (twice (QUOTE a)))", `"AA"`},
+		{"(BEGIN (DEFINE-SYNTAX inc! (SYNTAX-RULES () ((_) (SET! counter (CAT counter (QUOTE i))))))" +
+			" (DEFINE counter (QUOTE c)) (LET ((counter (QUOTE x))) (inc!)) counter)", `"CI"`},
+	}
+	for i, tc := range testcases {
+		engine := newMacroTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		got := val.String()
+		if got != tc.exp {
+			t.Errorf("%d: %v should evaluate to %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+		if val, err = runCompiled(newMacroTestEngine(), tc.src); err != nil || val.String() != tc.exp {
+			t.Errorf("%d: compiled %v should evaluate to %v, but got: %v / %v", i, tc.src, tc.exp, val, err)
+		}
+	}
+}
+
+func TestMacroError(t *testing.T) {
+	testcases := []struct {
+		src string
+		msg string
+	}{
+		{"(BEGIN " + srcSwap + " (swap! a))", "no syntax rule matches (A)"},
+		{"(DEFINE-SYNTAX x (QUOTE a))", "DEFINE-SYNTAX value A is not a macro"},
+		{"(DEFINE-SYNTAX x (SYNTAX-RULES () ((_ a ...) a)))", ""},
+		{"(BEGIN (DEFINE-SYNTAX x (SYNTAX-RULES () ((_ a ...) a))) (x b))", "pattern variable A must be followed by an ellipsis"},
+		{"(BEGIN (DEFINE-SYNTAX x (SYNTAX-RULES () ((_ a) (a ...)))) (x b))", "no pattern variable before ellipsis in template A"},
+	}
+	for i, tc := range testcases {
+		engine := newMacroTestEngine()
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		if tc.msg == "" {
+			if err != nil {
+				t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%d: %v should result in error, but got: %v", i, tc.src, val)
+			continue
+		}
+		if got := err.Error(); got != tc.msg {
+			t.Errorf("%d: %v should result in error %q, but got %q", i, tc.src, tc.msg, got)
+		}
+		if val, err = runCompiled(newMacroTestEngine(), tc.src); err == nil || err.Error() != tc.msg {
+			t.Errorf("%d: compiled %v should result in error %q, but got: %v / %v", i, tc.src, tc.msg, val, err)
+		}
+	}
+}
+
+func TestMacroExpand(t *testing.T) {
+	engine := newMacroTestEngine()
+	def, err := sxpf.ParseString(engine, srcUnless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = engine.Eval(def); err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(LIST (unless a b))", "(LIST (IF A () B))"},
+		{"(QUOTE (unless a b))", "(QUOTE (UNLESS A B))"},
+		{"(LAMBDA (unless a b) (unless a b))", "(LAMBDA (UNLESS A B) (IF A () B))"},
+		{"(LET ((x (unless a b))) (unless x y))", "(LET ((X (IF A () B))) (IF X () Y))"},
+		{"(COND ((unless a b) (unless c d)))", "(COND ((IF A () B) (IF C () D)))"},
+		{"[unless (unless a b) c]", "(IF (IF A () B) () C)"},
+	}
+	for i, tc := range testcases {
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Expand(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should expand to %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+	}
+}
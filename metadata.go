@@ -0,0 +1,193 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import "sync"
+
+// DocKey is the metadata key of the documentation of a value. Engine.DocKey
+// returns the key according to the case policy of an engine's symbol maker.
+var DocKey = MakeKeyword("DOC")
+
+// propList is a property list: a sequence of keys, each followed by its
+// value. Keys are compared by Equal.
+type propList []Value
+
+func (pl propList) get(key Value) (Value, bool) {
+	for i := 0; i < len(pl); i += 2 {
+		if pl[i].Equal(key) {
+			return pl[i+1], true
+		}
+	}
+	return nil, false
+}
+
+func (pl propList) put(key, val Value) propList {
+	for i := 0; i < len(pl); i += 2 {
+		if pl[i].Equal(key) {
+			pl[i+1] = val
+			return pl
+		}
+	}
+	return append(pl, key, val)
+}
+
+func (pl propList) remove(key Value) (propList, bool) {
+	for i := 0; i < len(pl); i += 2 {
+		if pl[i].Equal(key) {
+			return append(pl[:i:i], pl[i+2:]...), true
+		}
+	}
+	return pl, false
+}
+
+func (pl propList) asList() *Pair { return NewPairFromSlice(append([]Value(nil), pl...)) }
+
+// Metadata attaches additional values, like documentation, source positions,
+// or type hints, to other values. Values are identified by identity, so
+// metadata does not change their equality. Every value has its own property
+// list of metadata. Separately, every symbol has a property list. Metadata
+// may be used by multiple goroutines concurrently.
+//
+// Values with metadata and symbols with properties are not garbage
+// collected, as long as the Metadata is alive, even if they are not used
+// otherwise. Remove the metadata of values that are not needed anymore.
+type Metadata struct {
+	mx    sync.RWMutex
+	m     map[Value]propList
+	props map[*Symbol]propList
+}
+
+// NewMetadata creates a new, empty metadata store.
+func NewMetadata() *Metadata {
+	return &Metadata{m: map[Value]propList{}, props: map[*Symbol]propList{}}
+}
+
+// Get returns the metadata key of the given value.
+func (md *Metadata) Get(val, key Value) (Value, bool) {
+	md.mx.RLock()
+	defer md.mx.RUnlock()
+	return md.m[val].get(key)
+}
+
+// Put sets the metadata key of the given value.
+func (md *Metadata) Put(val, key, meta Value) {
+	md.mx.Lock()
+	defer md.mx.Unlock()
+	md.m[val] = md.m[val].put(key, meta)
+}
+
+// Remove removes the metadata key of the given value. It returns true, if
+// the metadata was set.
+func (md *Metadata) Remove(val, key Value) bool {
+	md.mx.Lock()
+	defer md.mx.Unlock()
+	pl, found := md.m[val].remove(key)
+	if len(pl) == 0 {
+		delete(md.m, val)
+	} else {
+		md.m[val] = pl
+	}
+	return found
+}
+
+// All returns the metadata of the given value as a property list.
+func (md *Metadata) All(val Value) *Pair {
+	md.mx.RLock()
+	defer md.mx.RUnlock()
+	return md.m[val].asList()
+}
+
+// GetProp returns the value of the property key of the symbol. Symbols that
+// share their bindings share their properties too.
+func (md *Metadata) GetProp(sym *Symbol, key Value) (Value, bool) {
+	md.mx.RLock()
+	defer md.mx.RUnlock()
+	return md.props[sym.key()].get(key)
+}
+
+// PutProp sets the property key of the symbol to the given value. A
+// transient symbol is promoted.
+func (md *Metadata) PutProp(sym *Symbol, key, val Value) {
+	sym.Promote()
+	k := sym.key()
+	md.mx.Lock()
+	defer md.mx.Unlock()
+	md.props[k] = md.props[k].put(key, val)
+}
+
+// RemoveProp removes the property key of the symbol. It returns true, if the
+// property was set.
+func (md *Metadata) RemoveProp(sym *Symbol, key Value) bool {
+	k := sym.key()
+	md.mx.Lock()
+	defer md.mx.Unlock()
+	pl, found := md.props[k].remove(key)
+	if len(pl) == 0 {
+		delete(md.props, k)
+	} else {
+		md.props[k] = pl
+	}
+	return found
+}
+
+// Props returns the property list of the symbol, i.e. a list of keys, each
+// followed by its value.
+func (md *Metadata) Props(sym *Symbol) *Pair {
+	md.mx.RLock()
+	defer md.mx.RUnlock()
+	return md.props[sym.key()].asList()
+}
+
+// Documented is implemented by values that carry their own documentation,
+// like builtins and closures with a docstring.
+type Documented interface {
+	Doc() string
+}
+
+// (DOC value) returns the documentation string of the value, or the empty
+// list, if the value is not documented.
+func docFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	if doc := e.Doc(args[0]); doc != "" {
+		return NewString(doc), nil
+	}
+	return Nil(), nil
+}
+
+// (META value key?) returns the metadata key of the value, or the empty
+// list. Without a key, the property list of all metadata is returned.
+func metaFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return e.state.meta.All(args[0]), nil
+	}
+	if meta, found := e.state.meta.Get(args[0], args[1]); found {
+		return meta, nil
+	}
+	return Nil(), nil
+}
+
+// (SET-META! value key meta) sets the metadata key of the value and returns
+// the value.
+func setMetaFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	e.state.meta.Put(args[0], args[1], args[2])
+	return args[0], nil
+}
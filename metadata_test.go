@@ -0,0 +1,113 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestSymbolProps(t *testing.T) {
+	smk := sxpf.NewSymbolMaker(sxpf.CasePreserving)
+	sym, other := smk.MakeSymbol("Color"), smk.MakeSymbol("COLOR")
+	key := sxpf.MakeKeyword("doc")
+	md := sxpf.NewMetadata()
+	md.PutProp(sym, key, sxpf.NewString("a color"))
+	if val, found := md.GetProp(other, key); !found || val.String() != `"a color"` {
+		t.Errorf("symbols sharing bindings must share properties, but got %v/%v", val, found)
+	}
+	if !sym.Equal(other) || sym.Equal(smk.MakeSymbol("shape")) {
+		t.Error("properties must not change equality")
+	}
+	if got := md.Props(sym).String(); got != `(:DOC "a color")` {
+		t.Errorf("unexpected property list %v", got)
+	}
+	if !md.RemoveProp(sym, key) || md.RemoveProp(sym, key) {
+		t.Error("property must be removed exactly once")
+	}
+	if got := md.Props(sym); got != nil {
+		t.Errorf("property list must be empty, but got %v", got)
+	}
+	if _, found := sxpf.NewMetadata().GetProp(sym, key); found {
+		t.Error("properties must be stored per metadata store")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	md := sxpf.NewMetadata()
+	s1, s2 := sxpf.NewString("a"), sxpf.NewString("a")
+	md.Put(s1, sxpf.DocKey, sxpf.NewString("doc"))
+	if _, found := md.Get(s2, sxpf.DocKey); found {
+		t.Error("metadata must be attached to identical values only")
+	}
+	if !s1.Equal(s2) {
+		t.Error("metadata must not change equality")
+	}
+	if got := md.All(s1).String(); got != `(:DOC "doc")` {
+		t.Errorf("unexpected metadata %v", got)
+	}
+	if !md.Remove(s1, sxpf.DocKey) || md.All(s1) != nil {
+		t.Error("metadata was not removed")
+	}
+}
+
+func TestDoc(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(DOC QUOTE)", `"(QUOTE value) returns value unevaluated."`},
+		{"(DOC 1)", "()"},
+		{`(BEGIN (DEFINE (f x) "Returns x." x) (DOC f))`, `"Returns x."`},
+		{`(BEGIN (DEFINE (f x) "Returns x." x) (f 3))`, "3"},
+		{`(BEGIN (DEFINE (f) "only value") (LIST (DOC f) (f)))`, `(() "only value")`},
+		{`(DOC (LAMBDA () "anonymous" 1))`, `"anonymous"`},
+		{`(BEGIN (DEFINE (f) 1) (SET-META! f :doc "later") (DOC f))`, `"later"`},
+		{`(BEGIN (DEFINE x 1) (SET-META! (QUOTE x) :type (QUOTE int)) (META (QUOTE x) :type))`, "INT"},
+		{`(BEGIN (DEFINE x 1) (SET-META! (QUOTE x) :type 1) (SET-META! (QUOTE x) :doc 2) (META (QUOTE x)))`, "(:TYPE 1 :DOC 2)"},
+		{"(META (QUOTE x) :type)", "()"},
+	}
+	for i, tc := range testcases {
+		engine := sxpf.NewEngine(sxpf.NewTrivialSymbolMaker())
+		engine.BindBuiltin(listForm)
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		val, err := engine.Eval(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, tc.src, err)
+			continue
+		}
+		if got := val.String(); got != tc.exp {
+			t.Errorf("%d: %v should evaluate to %v, but got: %v", i, tc.src, tc.exp, got)
+		}
+	}
+
+	engine := sxpf.NewEngine(sxpf.NewSymbolMaker(sxpf.CaseSensitive))
+	for _, src := range []string{":DOC", ":doc"} {
+		kw, err := sxpf.ParseString(engine, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val := sxpf.NewString(src)
+		engine.Metadata().Put(val, kw, sxpf.NewString("doc"))
+		exp := src == ":DOC"
+		if got := engine.Doc(val) != ""; got != exp {
+			t.Errorf("documentation with key %v should be found: %v, but got %v", src, exp, got)
+		}
+		if got := engine.DocKey().Equal(kw); got != exp {
+			t.Errorf("DocKey should be equal to %v: %v, but got %v", src, exp, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultNamespace is the name of the namespace, that is current when
+// Namespaces are created.
+const DefaultNamespace = "USER"
+
+// Namespaces is a SymbolMaker that creates symbols within namespaces. This
+// allows independently written libraries to use the same names without
+// collisions.
+//
+// A name of the form "ns/name" is a qualified name: it denotes the symbol
+// "name" of the namespace "ns". All other names denote a symbol of the
+// current namespace. Symbols of different namespaces are different, even if
+// they have the same name.
+//
+// If a symbol of a namespace is not bound, a SymbolMap looks up the symbols
+// with the same name, that are exported by the imported namespaces (see
+// Namespace.Import). Every namespace imports the default namespace, which
+// exports all of its symbols. Therefore, forms bound in the default
+// namespace, like the special forms of an Engine, are visible in all
+// namespaces, unless a namespace binds the name itself.
+//
+// Namespaces may be used by multiple goroutines concurrently.
+type Namespaces struct {
+	mx      sync.RWMutex
+	policy  CasePolicy
+	nss     map[string]*Namespace
+	def     *Namespace
+	current *Namespace
+}
+
+// NewNamespaces creates a new set of namespaces, that contains only the
+// default namespace. The case policy applies to names of symbols and
+// namespaces.
+func NewNamespaces(policy CasePolicy) *Namespaces {
+	nss := &Namespaces{policy: policy, nss: map[string]*Namespace{}}
+	def := nss.newNamespace(DefaultNamespace)
+	def.qualify, def.exportAll = false, true
+	nss.def, nss.current = def, def
+	nss.nss[nss.key(DefaultNamespace)] = def
+	return nss
+}
+
+func (nss *Namespaces) key(name string) string {
+	if nss.policy == CaseSensitive {
+		return name
+	}
+	return strings.ToUpper(name)
+}
+
+func (nss *Namespaces) newNamespace(name string) *Namespace {
+	if nss.policy == CaseFolding {
+		name = strings.ToUpper(name)
+	}
+	ns := &Namespace{name: name, qualify: true, exports: map[string]bool{}}
+	ns.symbols = newSymbolTable(nss.policy, ns)
+	return ns
+}
+
+// Namespace returns the namespace with the given name. If there is no such
+// namespace, a new one is created, which imports the default namespace.
+func (nss *Namespaces) Namespace(name string) *Namespace {
+	key := nss.key(name)
+	nss.mx.RLock()
+	ns, found := nss.nss[key]
+	nss.mx.RUnlock()
+	if found {
+		return ns
+	}
+	nss.mx.Lock()
+	defer nss.mx.Unlock()
+	if ns, found = nss.nss[key]; !found {
+		ns = nss.newNamespace(name)
+		ns.imports = []*Namespace{nss.def}
+		nss.nss[key] = ns
+	}
+	return ns
+}
+
+// Default returns the default namespace.
+func (nss *Namespaces) Default() *Namespace { return nss.def }
+
+// Current returns the current namespace.
+func (nss *Namespaces) Current() *Namespace {
+	nss.mx.RLock()
+	defer nss.mx.RUnlock()
+	return nss.current
+}
+
+// SetCurrent sets the current namespace and returns the previous one.
+func (nss *Namespaces) SetCurrent(ns *Namespace) *Namespace {
+	nss.mx.Lock()
+	defer nss.mx.Unlock()
+	prev := nss.current
+	nss.current = ns
+	return prev
+}
+
+// MakeSymbol returns the symbol with the given name. A qualified name
+// "ns/name" denotes a symbol of namespace "ns", all other names denote a
+// symbol of the current namespace.
+func (nss *Namespaces) MakeSymbol(s string) *Symbol {
+	if pos := strings.IndexByte(s, '/'); 0 < pos && pos < len(s)-1 {
+		return nss.Namespace(s[:pos]).MakeSymbol(s[pos+1:])
+	}
+	return nss.Current().MakeSymbol(s)
+}
+
+func (nss *Namespaces) findSymbol(s string) (*Symbol, bool) {
+	if pos := strings.IndexByte(s, '/'); 0 < pos && pos < len(s)-1 {
+		nss.mx.RLock()
+		ns, found := nss.nss[nss.key(s[:pos])]
+		nss.mx.RUnlock()
+		if found {
+			return ns.symbols.findSymbol(s[pos+1:])
+		}
+		// The namespace is not created before the symbol is promoted.
+		name := s
+		if nss.policy == CaseFolding {
+			name = strings.ToUpper(s)
+		}
+		sym := &Symbol{val: name, sensitive: nss.policy == CaseSensitive}
+		sym.home = &symbolHome{smk: nss, name: s}
+		return sym, false
+	}
+	return nss.Current().symbols.findSymbol(s)
+}
+
+// Namespace is a named set of symbols.
+type Namespace struct {
+	name      string
+	symbols   SymbolTable
+	qualify   bool // symbols are printed with the namespace name
+	mx        sync.RWMutex
+	exportAll bool
+	exports   map[string]bool
+	imports   []*Namespace
+}
+
+// Name returns the name of the namespace.
+func (ns *Namespace) Name() string { return ns.name }
+
+// MakeSymbol returns the symbol with the given name in this namespace.
+func (ns *Namespace) MakeSymbol(s string) *Symbol { return ns.symbols.MakeSymbol(s) }
+
+func (ns *Namespace) findSymbol(s string) (*Symbol, bool) { return ns.symbols.findSymbol(s) }
+
+// Export the symbols with the given names. They become visible in all
+// namespaces that import this namespace.
+func (ns *Namespace) Export(names ...string) {
+	ns.mx.Lock()
+	defer ns.mx.Unlock()
+	for _, name := range names {
+		if sym := ns.symbols.MakeSymbol(name); sym != nil {
+			ns.exports[sym.key().val] = true
+		}
+	}
+	atomic.AddUint64(&sharedGen, 1)
+}
+
+// Exports returns true, if the symbol with the given name is exported.
+func (ns *Namespace) Exports(name string) bool {
+	sym, _ := ns.symbols.findSymbol(name)
+	if sym == nil {
+		return false
+	}
+	ns.mx.RLock()
+	defer ns.mx.RUnlock()
+	return ns.exportAll || ns.exports[sym.key().val]
+}
+
+// Import the exported symbols of the given namespaces. When a symbol of this
+// namespace is looked up but not bound, the exported symbols with the same
+// name are looked up, in the order of import.
+func (ns *Namespace) Import(others ...*Namespace) {
+	ns.mx.Lock()
+	defer ns.mx.Unlock()
+	for _, other := range others {
+		if other != ns {
+			ns.imports = append(ns.imports, other)
+		}
+	}
+	atomic.AddUint64(&sharedGen, 1)
+}
+
+// importedSymbols returns the symbols with the same name as the given
+// symbol, that are exported by the namespaces imported by its namespace.
+func (sym *Symbol) importedSymbols() []*Symbol {
+	ns := sym.ns
+	if ns == nil {
+		return nil
+	}
+	ns.mx.RLock()
+	imports := ns.imports
+	ns.mx.RUnlock()
+	var result []*Symbol
+	for _, imp := range imports {
+		if isym, found := imp.findSymbol(sym.val); found && imp.Exports(sym.val) {
+			result = append(result, isym)
+		}
+	}
+	return result
+}
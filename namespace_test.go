@@ -0,0 +1,92 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestNamespaces(t *testing.T) {
+	nss := sxpf.NewNamespaces(sxpf.CaseFolding)
+	engine := sxpf.NewEngine(nss)
+	lib := nss.Namespace("lib")
+	lib.Export("get")
+	app := nss.Namespace("app")
+	app.Import(lib)
+
+	testcases := []struct {
+		ns  *sxpf.Namespace
+		src string
+		exp string
+	}{
+		{nss.Default(), "(DEFINE (get x) (QUOTE user))", "GET"},
+		{lib, "(DEFINE (get x) (QUOTE lib))", "LIB/GET"},
+		{lib, "(DEFINE (helper) (QUOTE helper))", "LIB/HELPER"},
+		{lib, "(get 1)", "LIB/LIB"},
+		{nss.Default(), "(get 1)", "USER"},
+		{nss.Default(), "(lib/get 1)", "LIB/LIB"},
+		{nss.Default(), "(QUOTE lib/get)", "LIB/GET"},
+		{nss.Default(), "(lib/helper)", "LIB/HELPER"},
+		{app, "(get 1)", "USER"},
+		{app, "(helper)", `symbol "HELPER" not found to form`},
+		{app, "(BEGIN (SET! lib/get (QUOTE x)) lib/get)", "APP/X"},
+		{lib, "get", "APP/X"},
+		{app, "(QUOTE (a user/b lib/c))", "(APP/A B LIB/C)"},
+	}
+	for i, tc := range testcases {
+		nss.SetCurrent(tc.ns)
+		expr, err := sxpf.ParseString(engine, tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var got string
+		if val, err2 := engine.Eval(expr); err2 != nil {
+			got = err2.Error()
+		} else {
+			got = val.String()
+		}
+		if got != tc.exp {
+			t.Errorf("%d: %v in %v should result in %v, but got: %v", i, tc.src, tc.ns.Name(), tc.exp, got)
+		}
+	}
+}
+
+func TestNamespaceSymbols(t *testing.T) {
+	nss := sxpf.NewNamespaces(sxpf.CaseFolding)
+	lib := nss.Namespace("lib")
+	if nss.MakeSymbol("lib/a") != lib.MakeSymbol("A") {
+		t.Error("qualified symbol must be the symbol of the namespace")
+	}
+	if sym := nss.MakeSymbol("a"); sym.Equal(lib.MakeSymbol("a")) {
+		t.Errorf("symbols of different namespaces must not be equal: %v", sym)
+	}
+	for _, s := range []string{"/", "a/", "/a"} {
+		if got := nss.MakeSymbol(s).String(); got != s && got != "A/" && got != "/A" {
+			t.Errorf("%q should not be qualified, but got %q", s, got)
+		}
+	}
+	if prev := nss.SetCurrent(lib); prev != nss.Default() {
+		t.Errorf("default namespace should be current, but got %v", prev.Name())
+	}
+	if nss.MakeSymbol("a") != lib.MakeSymbol("a") {
+		t.Error("unqualified symbol must be the symbol of the current namespace")
+	}
+	if lib.Exports("b") {
+		t.Error("symbol B should not be exported")
+	}
+	ts := sxpf.NewTransientSymbols(nss, 0)
+	if sym := ts.MakeSymbol("lib/b"); !sym.IsTransient() {
+		t.Error("querying an export must not create a symbol")
+	}
+}
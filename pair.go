@@ -74,6 +74,32 @@ func (p *Pair) GetSlice() []Value {
 	}
 }
 
+// getElems returns the elements of a possibly dotted pair list, together with
+// the value of the last pair's second field, if it is not a pair list.
+func (p *Pair) getElems() (elems []Value, tail Value) {
+	for cp := p; cp != nil; {
+		elems = append(elems, cp.first)
+		np, ok := cp.second.(*Pair)
+		if !ok {
+			return elems, cp.second
+		}
+		cp = np
+	}
+	return elems, nil
+}
+
+// newDottedList creates a pair list of the given elements, where the second
+// field of the last pair contains the given tail value.
+func newDottedList(elems []Value, tail Value) Value {
+	if tail == nil {
+		tail = Nil()
+	}
+	for i := len(elems) - 1; i >= 0; i-- {
+		tail = NewPair(elems[i], tail)
+	}
+	return tail
+}
+
 // Nil() returns the empty pair.
 func Nil() *Pair { return nilPair }
 
@@ -15,6 +15,7 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 // ErrMissingOpenBracket is raised if there is one additional closing bracket.
@@ -44,6 +45,15 @@ var ErrMissingQuote = errors.New("missing quote character")
 // ErrMissing EOF is raised if there is additional input after an expression.
 var ErrMissingEOF = errors.New("missing end of input")
 
+// ErrInvalidChar is raised if a character literal is not valid.
+var ErrInvalidChar = errors.New("invalid character")
+
+// ErrInvalidBytes is raised if a byte string literal is not valid.
+var ErrInvalidBytes = errors.New("invalid byte string")
+
+// ErrTooManySymbols is raised if the symbol maker cannot create more symbols.
+var ErrTooManySymbols = errors.New("too many symbols")
+
 // ErrUnknownToken is raised if an unexpected token occured.
 var ErrUnknownToken = errors.New("unknown token")
 
@@ -82,8 +92,13 @@ type Parser struct {
 	sc         *Scanner
 	tbuf       []*Token
 	maxNesting uint
+	positions  Positions
+	uninterned map[string]*Symbol
 }
 
+// Positions stores the source positions of parsed pair lists and vectors.
+type Positions map[Value]Position
+
 func NewParser(smk SymbolMaker, rr RuneReader) *Parser {
 	return &Parser{
 		smk:        smk,
@@ -99,6 +114,15 @@ func (pa *Parser) SetMaxNesting(n uint) uint {
 	return prevN
 }
 
+// SetPositions sets the table, where the source positions of all parsed pair
+// lists and vectors are stored. The previous table is returned. A nil table
+// disables storing positions.
+func (pa *Parser) SetPositions(ps Positions) Positions {
+	prevPs := pa.positions
+	pa.positions = ps
+	return prevPs
+}
+
 func (pa *Parser) Parse() (Value, error) {
 	return pa.parseValue(pa.next())
 }
@@ -179,11 +203,20 @@ func (pa *Parser) parseValue(tok Token) (Value, error) {
 	case TokErr:
 		return nil, pa.err()
 	case TokLeftParen:
-		return pa.parseList()
+		val, err := pa.parseList()
+		pa.storePosition(val, tok.Pos)
+		return val, err
 	case TokLeftBrack:
-		return pa.parseVector()
+		val, err := pa.parseVector()
+		pa.storePosition(val, tok.Pos)
+		return val, err
 	case TokString:
 		return NewString(tok.Val), nil
+	case TokBytes:
+		return NewBytes([]byte(tok.Val)), nil
+	case TokChar:
+		r, _ := utf8.DecodeRuneInString(tok.Val)
+		return NewChar(r), nil
 	case TokRightParen, TokPeriod:
 		return nil, ErrMissingOpenParenthesis
 	case TokRightBrack:
@@ -191,12 +224,99 @@ func (pa *Parser) parseValue(tok Token) (Value, error) {
 	case TokRightCurly:
 		return nil, ErrMissingOpenCurly
 	case TokSymbol:
-		return pa.smk.MakeSymbol(tok.Val), nil
+		if i, ok := ParseInteger(tok.Val); ok {
+			return i, nil
+		}
+		if b, ok := parseBoolean(tok.Val); ok {
+			return b, nil
+		}
+		if len(tok.Val) > 1 && tok.Val[0] == ':' {
+			return newKeyword(tok.Val[1:], casePolicyOf(pa.smk)), nil
+		}
+		if len(tok.Val) > 2 && strings.HasPrefix(tok.Val, "#:") {
+			return pa.uninternedSymbol(tok.Val[2:]), nil
+		}
+		if b := pa.builtinRef(tok.Val); b != nil {
+			return b, nil
+		}
+		if sym := pa.smk.MakeSymbol(tok.Val); sym != nil {
+			return sym, nil
+		}
+		return nil, ErrTooManySymbols
 	default:
 		return nil, ErrUnknownToken
 	}
 }
 
+// builtinRef returns the builtin referenced by "#NAME", i.e. by the way a
+// builtin is printed. This is only possible, if the symbol maker is an
+// environment, where the builtin is bound to the symbol NAME. Otherwise, nil
+// is returned. The symbol NAME is only looked up, it is not created.
+func (pa *Parser) builtinRef(s string) *Builtin {
+	env, isEnv := pa.smk.(Environment)
+	if !isEnv || len(s) < 2 || s[0] != '#' {
+		return nil
+	}
+	sym := findSymbol(env, s[1:])
+	if sym == nil {
+		return nil
+	}
+	if form, err := env.LookupForm(sym); err == nil {
+		if b, isBuiltin := form.(*Builtin); isBuiltin {
+			return b
+		}
+	}
+	return nil
+}
+
+// findSymbol returns the symbol of the environment's symbol maker with the
+// given name, without storing a new symbol. If the symbol maker does not
+// support this, nil is returned.
+func findSymbol(env Environment, s string) *Symbol {
+	var smk SymbolMaker
+	switch e := env.(type) {
+	case *Engine:
+		smk = e.smk
+	case *BasicEnvironment:
+		smk = e.smk
+	}
+	if finder, ok := smk.(symbolFinder); ok {
+		sym, _ := finder.findSymbol(s)
+		return sym
+	}
+	return nil
+}
+
+// uninternedSymbol returns an uninterned symbol with the given name. Within
+// one parser, the same name denotes the same uninterned symbol.
+func (pa *Parser) uninternedSymbol(name string) *Symbol {
+	if sym, found := pa.uninterned[name]; found {
+		return sym
+	}
+	if pa.uninterned == nil {
+		pa.uninterned = map[string]*Symbol{}
+	}
+	sym := NewUninternedSymbol(name)
+	pa.uninterned[name] = sym
+	return sym
+}
+
+func (pa *Parser) storePosition(val Value, pos Position) {
+	if pa.positions == nil || val == nil {
+		return
+	}
+	switch v := val.(type) {
+	case *Pair:
+		if v != nil {
+			pa.positions[v] = pos
+		}
+	case *Vector:
+		if len(v.GetSlice()) > 0 {
+			pa.positions[v] = pos
+		}
+	}
+}
+
 func (pa *Parser) parseVector() (Value, error) {
 	elems := []Value{}
 	for {
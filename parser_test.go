@@ -53,6 +53,19 @@ func TestParseString(t *testing.T) {
 		{"(A.B)", "(A . B)"},
 		{`("A"."B")`, `("A" . "B")`},
 		{`("A".b)`, `("A" . B)`},
+		{"(a ...)", "(A ...)"},
+		{"(a ... . b)", "(A ... . B)"},
+
+		{"0", "0"}, {"+17", "17"}, {"-4", "-4"}, {"-", "-"}, {"1a", "1A"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"(1 . 2)", "(1 . 2)"},
+		{"#t", "#t"}, {"#F", "#f"}, {"#true", "#t"}, {"(#f () [])", "(#f () [])"}, {"#tx", "#TX"},
+		{`#\a`, `#\a`}, {`#\A`, `#\A`}, {`#\(`, `#\(`}, {`#\SPACE`, `#\space`}, {`#\x41`, `#\A`},
+		{`#\u00e4`, `#\ä`}, {`#\x00`, `#\nul`}, {`#\xa0`, `#\xa0`}, {`#\x`, `#\x`}, {`#\\`, `#\\`},
+		{`(#\a #\) #\b)`, `(#\a #\) #\b)`}, {"#", "#"}, {"#a", "#A"},
+		{"#u8()", `#base64""`}, {"#u8(1 2 255)", `#base64"AQL/"`}, {"#U8( 104\n105 )", `#base64"aGk="`},
+		{`#base64"AQL/"`, `#base64"AQL/"`}, {`#BASE64" AQ L/ "`, `#base64"AQL/"`}, {"(#u8(1) #u8)", `(#base64"AQ==" #U8)`},
+		{":a", ":A"}, {":", ":"}, {"(:key 1)", "(:KEY 1)"},
 
 		{"[]", "[]"},
 		{"[a]", "[A]"},
@@ -161,6 +174,15 @@ func TestParseBytesWithError(t *testing.T) {
 		{`["]`, sxpf.ErrMissingQuote.Error()},
 		{`["][]`, sxpf.ErrMissingQuote.Error()},
 
+		{`#\`, sxpf.ErrInvalidChar.Error()},
+		{`#\ab`, sxpf.ErrInvalidChar.Error()},
+		{`#\x4`, sxpf.ErrInvalidChar.Error()},
+		{"#u8(1 256)", sxpf.ErrInvalidBytes.Error()},
+		{"#u8(1 a)", sxpf.ErrInvalidBytes.Error()},
+		{"#u8(1", sxpf.ErrInvalidBytes.Error()},
+		{`#base64"A"`, sxpf.ErrInvalidBytes.Error()},
+		{`#base64"AQ==`, sxpf.ErrMissingQuote.Error()},
+
 		{`"`, sxpf.ErrMissingQuote.Error()},
 		{`"a`, sxpf.ErrMissingQuote.Error()},
 		{`"\`, sxpf.ErrMissingQuote.Error()},
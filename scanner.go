@@ -12,8 +12,13 @@ package sxpf
 
 import (
 	"bytes"
+	"encoding/base64"
+	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // TokenType enumerates the concrete type of token.
@@ -32,24 +37,41 @@ const (
 	TokRightCurly                  // }
 	TokSymbol                      // symbol
 	TokString                      // "..."
+	TokChar                        // #\c
+	TokBytes                       // #u8(...) or #base64"..."
 )
 
 // Token is the result of calling a scanner.
 type Token struct {
 	Typ TokenType
 	Val string
+	Pos Position // position of the first character of the token
 }
 
+// Position is the location of a character within the source of a scanner.
+type Position struct {
+	Line   int // starting with 1; 0 means: unknown position
+	Column int // starting with 1, counting unicode characters
+}
+
+func (p Position) String() string { return fmt.Sprintf("%d:%d", p.Line, p.Column) }
+
+// IsValid returns true, if the position is known.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
 // Scanner are returning Token from a Reader.
 type Scanner struct {
-	rd  RuneReader
-	pos uint64 // current bye position in Reader
-	err error
+	rd      RuneReader
+	pos     uint64   // current bye position in Reader
+	next    Position // position of the next character to read
+	chPos   Position // position of the last read character
+	prevPos Position // value of next before reading the last character
+	err     error
 }
 
 // NewScanner creates a new scanner.
 func NewScanner(rd RuneReader) *Scanner {
-	return &Scanner{rd, 0, nil}
+	return &Scanner{rd: rd, next: Position{1, 1}}
 }
 
 func (s *Scanner) Err() error { return s.err }
@@ -72,9 +94,23 @@ func (s *Scanner) read() rune {
 		return chErr
 	}
 	s.pos += uint64(width)
+	s.prevPos, s.chPos = s.next, s.next
+	if ch == '\n' {
+		s.next = Position{s.next.Line + 1, 1}
+	} else {
+		s.next.Column++
+	}
 	return ch
 }
 
+func (s *Scanner) unread() error {
+	err := s.rd.UnreadRune()
+	if err == nil {
+		s.next = s.prevPos
+	}
+	return err
+}
+
 func (s *Scanner) Next() Token {
 	ch := s.read()
 	for {
@@ -89,65 +125,248 @@ func (s *Scanner) Next() Token {
 		}
 
 	}
+	pos := s.chPos
+	tok := s.nextToken(ch)
+	tok.Pos = pos
+	return tok
+}
 
+func (s *Scanner) nextToken(ch rune) Token {
 	switch ch {
 	case chEOF:
-		return Token{TokEOF, ""}
+		return Token{Typ: TokEOF, Val: ""}
 	case chErr:
-		return Token{TokErr, s.err.Error()}
+		return Token{Typ: TokErr, Val: s.err.Error()}
 	case '(':
-		return Token{TokLeftParen, "("}
+		return Token{Typ: TokLeftParen, Val: "("}
 	case '.':
-		return Token{TokPeriod, "."}
+		return s.nextPeriod()
 	case ')':
-		return Token{TokRightParen, ")"}
+		return Token{Typ: TokRightParen, Val: ")"}
 	case '[':
-		return Token{TokLeftBrack, "["}
+		return Token{Typ: TokLeftBrack, Val: "["}
 	case ']':
-		return Token{TokRightBrack, "]"}
+		return Token{Typ: TokRightBrack, Val: "]"}
 	case '{':
-		return Token{TokLeftCurly, "{"}
+		return Token{Typ: TokLeftCurly, Val: "{"}
 	case '}':
-		return Token{TokRightCurly, "}"}
+		return Token{Typ: TokRightCurly, Val: "}"}
 	case '"':
 		return s.nextString()
+	case '#':
+		return s.nextHash()
 	}
 	if unicode.In(ch, unicode.C) {
 		// TODO: invalid unicode char at position
 		s.err = io.EOF
-		return Token{TokErr, s.err.Error()}
+		return Token{Typ: TokErr, Val: s.err.Error()}
 	}
 	return s.nextSymbol(ch)
 }
 
 func (s *Scanner) nextSymbol(ch rune) Token {
 	var buf bytes.Buffer
+	buf.WriteRune(ch)
+	if !s.readSymbol(&buf) {
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	}
+	return Token{Typ: TokSymbol, Val: buf.String()}
+}
+
+// readSymbol appends all following characters of a symbol to the buffer.
+func (s *Scanner) readSymbol(buf *bytes.Buffer) bool {
 	for {
-		buf.WriteRune(ch)
-		ch = s.read()
+		ch := s.read()
 		switch ch {
 		case chEOF:
-			return Token{TokSymbol, buf.String()}
+			return true
 		case '(', '.', ')', '[', ']', '{', '}', '"', ';':
-			err := s.rd.UnreadRune()
+			err := s.unread()
 			if err == nil {
-				return Token{TokSymbol, buf.String()}
+				return true
 			}
 			s.err = err
 			fallthrough
 		case chErr:
-			return Token{TokErr, s.err.Error()}
+			return false
 		}
 		if unicode.IsSpace(ch) {
-			// No need to unread, since space will be skipped next time
-			return Token{TokSymbol, buf.String()}
+			// Unread, so that a following peek sees the space
+			if err := s.unread(); err != nil {
+				s.err = err
+				return false
+			}
+			return true
 		}
 		if unicode.In(ch, unicode.C) {
 			// TODO: invalid unicode char at position
 			s.err = io.EOF
-			return Token{TokErr, s.err.Error()}
+			return false
+		}
+		buf.WriteRune(ch)
+	}
+}
+
+// peek returns the next character without consuming it.
+func (s *Scanner) peek() rune {
+	ch := s.read()
+	if ch != chEOF && ch != chErr {
+		if err := s.unread(); err != nil {
+			s.err = err
+			return chErr
 		}
 	}
+	return ch
+}
+
+// nextHash returns a character token, if the hash sign is followed by a
+// backslash, and a bytes token, if it is followed by "u8(" or 'base64"'.
+// Otherwise it returns a symbol that starts with the hash sign.
+func (s *Scanner) nextHash() Token {
+	ch := s.read()
+	switch ch {
+	case '\\':
+		return s.nextChar()
+	case chErr:
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	case chEOF:
+		return Token{Typ: TokSymbol, Val: "#"}
+	}
+	if err := s.unread(); err != nil {
+		s.err = err
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('#')
+	if !s.readSymbol(&buf) {
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	}
+	name := buf.String()
+	if strings.EqualFold(name, "#u8") && s.peek() == '(' {
+		s.read()
+		return s.nextByteList()
+	}
+	if strings.EqualFold(name, "#base64") && s.peek() == '"' {
+		s.read()
+		return s.nextBase64()
+	}
+	if s.err != nil {
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	}
+	return Token{Typ: TokSymbol, Val: name}
+}
+
+// nextByteList returns a bytes token for a list of decimal byte values.
+func (s *Scanner) nextByteList() Token {
+	var result []byte
+	for {
+		ch := s.read()
+		for unicode.IsSpace(ch) {
+			ch = s.read()
+		}
+		switch ch {
+		case ')':
+			return Token{Typ: TokBytes, Val: string(result)}
+		case chEOF:
+			s.err = ErrInvalidBytes
+			fallthrough
+		case chErr:
+			return Token{Typ: TokErr, Val: s.err.Error()}
+		}
+		var buf bytes.Buffer
+		buf.WriteRune(ch)
+		if !s.readSymbol(&buf) {
+			return Token{Typ: TokErr, Val: s.err.Error()}
+		}
+		b, err := strconv.ParseUint(buf.String(), 10, 8)
+		if err != nil {
+			s.err = ErrInvalidBytes
+			return Token{Typ: TokErr, Val: s.err.Error()}
+		}
+		result = append(result, byte(b))
+	}
+}
+
+// nextBase64 returns a bytes token for a base64 encoded string. White space
+// within the string is ignored.
+func (s *Scanner) nextBase64() Token {
+	tok := s.nextString()
+	if tok.Typ == TokErr {
+		return tok
+	}
+	result, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(tok.Val), ""))
+	if err != nil {
+		s.err = ErrInvalidBytes
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	}
+	return Token{Typ: TokBytes, Val: string(result)}
+}
+
+// nextChar returns a character token. The character is given literally, by
+// its name (see CharNames), or by its hexadecimal code, like in strings.
+func (s *Scanner) nextChar() Token {
+	var buf bytes.Buffer
+	ch := s.read()
+	switch ch {
+	case chEOF:
+		s.err = ErrInvalidChar
+		fallthrough
+	case chErr:
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	case 'x':
+		s.parseRune(&buf, ch, 2, nil)
+	case 'u':
+		s.parseRune(&buf, ch, 4, nil)
+	case 'U':
+		s.parseRune(&buf, ch, 6, nil)
+	default:
+		buf.WriteRune(ch)
+	}
+	if s.err != nil {
+		return Token{Typ: TokErr, Val: s.err.Error()}
+	}
+	switch ch {
+	case '(', '.', ')', '[', ']', '{', '}', '"', ';':
+	default:
+		if !unicode.IsSpace(ch) && !s.readSymbol(&buf) {
+			return Token{Typ: TokErr, Val: s.err.Error()}
+		}
+	}
+	if val := buf.String(); utf8.RuneCountInString(val) == 1 {
+		return Token{Typ: TokChar, Val: val}
+	}
+	if r, found := lookupCharName(buf.String()); found {
+		return Token{Typ: TokChar, Val: string(r)}
+	}
+	s.err = ErrInvalidChar
+	return Token{Typ: TokErr, Val: s.err.Error()}
+}
+
+// nextPeriod returns a period token, or a symbol token if there are at least
+// two periods, like the ellipsis "...".
+func (s *Scanner) nextPeriod() Token {
+	var buf bytes.Buffer
+	buf.WriteByte('.')
+	for {
+		ch := s.read()
+		switch ch {
+		case '.':
+			buf.WriteByte('.')
+			continue
+		case chErr:
+			return Token{Typ: TokErr, Val: s.err.Error()}
+		case chEOF:
+		default:
+			if err := s.unread(); err != nil {
+				s.err = err
+				return Token{Typ: TokErr, Val: s.err.Error()}
+			}
+		}
+		if buf.Len() == 1 {
+			return Token{Typ: TokPeriod, Val: "."}
+		}
+		return Token{Typ: TokSymbol, Val: buf.String()}
+	}
 }
 
 func (s *Scanner) nextString() Token {
@@ -159,9 +378,9 @@ func (s *Scanner) nextString() Token {
 			s.err = ErrMissingQuote
 			fallthrough
 		case chErr:
-			return Token{TokErr, s.err.Error()}
+			return Token{Typ: TokErr, Val: s.err.Error()}
 		case '"':
-			return Token{TokString, buf.String()}
+			return Token{Typ: TokString, Val: buf.String()}
 		case '\\':
 			ch = s.read()
 			switch ch {
@@ -169,7 +388,7 @@ func (s *Scanner) nextString() Token {
 				s.err = ErrMissingQuote
 				fallthrough
 			case chErr:
-				return Token{TokErr, s.err.Error()}
+				return Token{Typ: TokErr, Val: s.err.Error()}
 			case 't':
 				buf.WriteByte('\t')
 			case 'r':
@@ -177,11 +396,11 @@ func (s *Scanner) nextString() Token {
 			case 'n':
 				buf.WriteByte('\n')
 			case 'x':
-				s.parseRune(&buf, ch, 2)
+				s.parseRune(&buf, ch, 2, ErrMissingQuote)
 			case 'u':
-				s.parseRune(&buf, ch, 4)
+				s.parseRune(&buf, ch, 4, ErrMissingQuote)
 			case 'U':
-				s.parseRune(&buf, ch, 6)
+				s.parseRune(&buf, ch, 6, ErrMissingQuote)
 			default:
 				buf.WriteRune(ch)
 			}
@@ -191,14 +410,25 @@ func (s *Scanner) nextString() Token {
 	}
 }
 
-func (s *Scanner) parseRune(buf *bytes.Buffer, curCh rune, numDigits int) {
+// parseRune reads numDigits hexadecimal digits and writes the rune with this
+// code into the buffer. If there are not enough digits, curCh and all digits
+// read so far are written instead. At the end of input, eofErr is set as the
+// error of the scanner, if it is not nil.
+func (s *Scanner) parseRune(buf *bytes.Buffer, curCh rune, numDigits int, eofErr error) {
 	var arr [8]rune
 	result := rune(0)
 	for i := 0; i < numDigits; i++ {
 		ch := s.read()
 		switch ch {
 		case chEOF:
-			s.err = ErrMissingQuote
+			if eofErr != nil {
+				s.err = eofErr
+				return
+			}
+			buf.WriteRune(curCh)
+			for j := 0; j < i; j++ {
+				buf.WriteRune(arr[j])
+			}
 			return
 		case chErr:
 			return
@@ -271,7 +501,7 @@ func (s *Scanner) parseRune(buf *bytes.Buffer, curCh rune, numDigits int) {
 			for j := 0; j < i; j++ {
 				buf.WriteRune(arr[j])
 			}
-			s.err = s.rd.UnreadRune()
+			s.err = s.unread()
 			return
 		}
 	}
@@ -39,6 +39,10 @@ func TestScanner(t *testing.T) {
 		{"(", "("},
 		{"(.)[{}]", "(.)[{}]"},
 		{"a.", "a."},
+		{"...", "..."},
+		{"(a ...)", "(a...)"},
+		{"(a . b)", "(a.b)"},
+		{".. .", "..."},
 		{`""`, ``},
 		{`"a"`, `a`},
 		{`"\""`, `"`},
@@ -50,6 +54,7 @@ func TestScanner(t *testing.T) {
 		{`"\u"`, `u`}, {`"\u0"`, `u0`}, {`"\u00"`, `u00`}, {`"\u004"`, `u004`}, {`"\u0042"`, `B`},
 		{`"\U"`, `U`}, {`"\U0"`, `U0`}, {`"\U00"`, `U00`}, {`"\U000"`, `U000`}, {`"\U0000"`, `U0000`},
 		{`"\U00004"`, `U00004`}, {`"\U000043"`, `C`},
+		{`#\a`, `a`}, {`#\(`, `(`}, {`#\space`, ` `}, {`#\x41`, `A`}, {`(#\a)`, `(a)`}, {`#\..`, `..`},
 	}
 	for i, tc := range testcases {
 		var buf bytes.Buffer
@@ -71,3 +76,18 @@ func TestScanner(t *testing.T) {
 		}
 	}
 }
+
+func TestScannerPosition(t *testing.T) {
+	t.Parallel()
+	s := sxpf.NewScanner(strings.NewReader("(a\n  \"b\" ; c\n ...) äb.c"))
+	exp := []string{"1:1", "1:2", "2:3", "3:2", "3:5", "3:7", "3:9", "3:10"}
+	for i, pos := range exp {
+		tok := s.Next()
+		if got := tok.Pos.String(); got != pos {
+			t.Errorf("%d: token %q should be at %v, but got %v", i, tok.Val, pos, got)
+		}
+	}
+	if tok := s.Next(); tok.Typ != sxpf.TokEOF {
+		t.Errorf("EOF expected, but got %v", tok)
+	}
+}
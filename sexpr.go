@@ -74,6 +74,28 @@ func GetString(args []Value, idx int) (string, error) {
 	return "", fmt.Errorf("%v / %d is not a string", args[idx], idx)
 }
 
+// GetInteger returns the idx value of args as an integer.
+func GetInteger(args []Value, idx int) (int64, error) {
+	if idx < 0 || len(args) <= idx {
+		return 0, makeErrIndexOutOfBounds(args, idx)
+	}
+	if val, ok := args[idx].(*Integer); ok {
+		return val.GetValue(), nil
+	}
+	return 0, fmt.Errorf("%v / %d is not an integer", args[idx], idx)
+}
+
+// GetKeyword returns the idx value of args as a Keyword.
+func GetKeyword(args []Value, idx int) (*Keyword, error) {
+	if idx < 0 || len(args) <= idx {
+		return nil, makeErrIndexOutOfBounds(args, idx)
+	}
+	if val, ok := args[idx].(*Keyword); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("%v / %d is not a keyword", args[idx], idx)
+}
+
 // GetSequence returns the idx value of args as a sequence.
 func GetSequence(args []Value, idx int) (Sequence, error) {
 	if idx < 0 || len(args) <= idx {
@@ -85,6 +107,17 @@ func GetSequence(args []Value, idx int) (Sequence, error) {
 	return nil, fmt.Errorf("%v / %d is not a sequence", args[idx], idx)
 }
 
+// GetForm returns the idx value of args as a Form.
+func GetForm(args []Value, idx int) (Form, error) {
+	if idx < 0 || len(args) <= idx {
+		return nil, makeErrIndexOutOfBounds(args, idx)
+	}
+	if val, ok := args[idx].(Form); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("%v / %d is not a form", args[idx], idx)
+}
+
 func makeErrIndexOutOfBounds(args []Value, idx int) error {
 	return fmt.Errorf("index %d out of bounds: %v", idx, args)
 }
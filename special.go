@@ -0,0 +1,253 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// coreForms are the special forms and builtins bound in the top-level scope of an Engine.
+var coreForms = []*Builtin{
+	NewBuiltin("QUOTE", true, 1, 1, quoteFn).WithDoc("(QUOTE value) returns value unevaluated."),
+	NewBuiltin("IF", true, 2, 3, ifFn).WithDoc("(IF test then else?) evaluates then or else, depending on test."),
+	NewBuiltin("COND", true, 0, -1, condFn).WithDoc("(COND (test expr...)...) evaluates the first clause with a true test."),
+	NewBuiltin("BEGIN", true, 0, -1, beginFn).WithDoc("(BEGIN expr...) evaluates all expressions and returns the last result."),
+	NewBuiltin("DEFINE", true, 1, -1, defineFn).WithDoc("(DEFINE sym expr) or (DEFINE (sym param...) doc? body...) binds sym."),
+	NewBuiltin("SET!", true, 2, 2, setFn).WithDoc("(SET! sym expr) changes the value of the bound symbol sym."),
+	NewBuiltin("LAMBDA", true, 1, -1, lambdaFn).WithDoc("(LAMBDA params doc? body...) creates a closure."),
+	NewBuiltin("LET", true, 1, -1, letFn).WithDoc("(LET ((sym expr)...) body...) evaluates body with local bindings."),
+	NewBuiltin("DEFMACRO", true, 2, -1, defmacroFn).WithDoc("(DEFMACRO name params body...) binds name to a macro."),
+	NewBuiltin("DEFINE-SYNTAX", true, 2, 2, defineSyntaxFn).WithDoc("(DEFINE-SYNTAX name expr) binds name to a macro."),
+	NewBuiltin("SYNTAX-RULES", true, 1, -1, syntaxRulesFn).WithDoc("(SYNTAX-RULES (literal...) (pattern template)...) creates a macro."),
+	NewBuiltin("GENSYM", false, 0, 1, gensymFn).WithDoc("(GENSYM prefix?) returns a new uninterned symbol."),
+	NewBuiltin("MACROEXPAND-1", false, 1, 1, macroExpand1Fn).WithDoc("(MACROEXPAND-1 value) expands value once."),
+	NewBuiltin("MACROEXPAND", false, 1, 1, macroExpandFn).WithDoc("(MACROEXPAND value) expands value, until it is not a macro call."),
+	NewBuiltin("RAISE", false, 1, 3, raiseFn).WithDoc("(RAISE tag message? data?) raises an error value."),
+	NewBuiltin("HANDLER-CASE", true, 1, -1, handlerCaseFn).WithDoc("(HANDLER-CASE expr (tag (var?) body...)...) handles errors of expr."),
+	NewBuiltin("UNWIND-PROTECT", true, 1, -1, unwindProtectFn).WithDoc("(UNWIND-PROTECT expr cleanup...) evaluates cleanup in every case."),
+	NewBuiltin("ERROR-TAG", false, 1, 1, errorTagFn).WithDoc("(ERROR-TAG error) returns the tag of the error value."),
+	NewBuiltin("ERROR-MESSAGE", false, 1, 1, errorMessageFn).WithDoc("(ERROR-MESSAGE error) returns the message of the error value."),
+	NewBuiltin("ERROR-DATA", false, 1, 1, errorDataFn).WithDoc("(ERROR-DATA error) returns the data of the error value."),
+	NewBuiltin("FUNCALL", false, 1, -1, funcallFn).WithDoc("(FUNCALL form arg...) calls form with the arguments."),
+	NewBuiltin("APPLY", false, 2, -1, applyFn).WithDoc("(APPLY form arg... seq) calls form with the arguments and the elements of seq."),
+	NewBuiltin("DOC", false, 1, 1, docFn).WithDoc("(DOC value) returns the documentation of value."),
+	NewBuiltin("META", false, 1, 2, metaFn).WithDoc("(META value key?) returns the metadata of value."),
+	NewBuiltin("SET-META!", false, 3, 3, setMetaFn).WithDoc("(SET-META! value key meta) sets the metadata key of value."),
+}
+
+func getEngine(env Environment) (*Engine, error) {
+	if e, ok := env.(*Engine); ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("environment %T is not an engine", env)
+}
+
+// (QUOTE value) returns value unevaluated.
+func quoteFn(_ Environment, args []Value) (Value, error) { return args[0], nil }
+
+// (IF test then else?) evaluates then, if test evaluates to a true value.
+// Otherwise else is evaluated, which defaults to the empty list.
+func ifFn(env Environment, args []Value) (Value, error) {
+	test, err := Evaluate(env, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if IsTrue(test) {
+		return TailCall(env, args[1]), nil
+	}
+	if len(args) > 2 {
+		return TailCall(env, args[2]), nil
+	}
+	return Nil(), nil
+}
+
+// (COND (test expr...)...) evaluates the expressions of the first clause,
+// whose test evaluates to a true value. The test ELSE is always true.
+func condFn(env Environment, args []Value) (Value, error) {
+	for _, arg := range args {
+		clause, ok := arg.(Sequence)
+		if !ok {
+			return nil, fmt.Errorf("COND clause %v is not a sequence", arg)
+		}
+		vals := clause.GetSlice()
+		if len(vals) == 0 {
+			return nil, fmt.Errorf("empty COND clause")
+		}
+		test := vals[0]
+		if !isElse(env, test) {
+			var err error
+			if test, err = Evaluate(env, test); err != nil {
+				return nil, err
+			}
+		}
+		if !IsTrue(test) {
+			continue
+		}
+		if len(vals) == 1 {
+			return test, nil
+		}
+		return evaluateBody(env, vals[1:])
+	}
+	return Nil(), nil
+}
+
+// isElse returns true, if the value is the symbol ELSE.
+func isElse(env Environment, val Value) bool { return isNamedSymbol(env, val, "ELSE") }
+
+// isNamedSymbol returns true, if the value is a symbol with the given name.
+// The case policy of the symbol maker of the environment applies. A symbol
+// renamed by a macro expansion keeps the name of its original symbol.
+func isNamedSymbol(env Environment, val Value, name string) bool {
+	sym, ok := val.(*Symbol)
+	if !ok || sym.uninterned {
+		return false
+	}
+	smk, _ := env.(SymbolMaker)
+	if sym.sensitive || casePolicyOf(smk) == CaseSensitive {
+		return sym.val == name
+	}
+	return strings.EqualFold(sym.val, name)
+}
+
+// (BEGIN expr...) evaluates all expressions and returns the last result.
+func beginFn(env Environment, args []Value) (Value, error) { return evaluateBody(env, args) }
+
+// (DEFINE sym expr) binds sym to the value of expr in the current scope.
+// (DEFINE (sym param...) body...) binds sym to a closure.
+func defineFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := args[0].(*Pair); ok && p != nil {
+		sym, isSymbol := p.GetFirst().(*Symbol)
+		if !isSymbol {
+			return nil, fmt.Errorf("DEFINE name %v is not a symbol", p.GetFirst())
+		}
+		c, err2 := newClosure(e, p.GetSecond(), args[1:])
+		if err2 != nil {
+			return nil, err2
+		}
+		c.name = sym.GetValue()
+		e.Define(sym, c)
+		return sym, nil
+	}
+	sym, err := GetSymbol(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("DEFINE of symbol %v needs exactly one value", sym)
+	}
+	val, err := Evaluate(e, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if c, isClosure := val.(*Closure); isClosure && c.name == "" {
+		c.name = sym.GetValue()
+	}
+	e.Define(sym, val)
+	return sym, nil
+}
+
+// (SET! sym expr) changes the value of the already bound symbol sym.
+func setFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := GetSymbol(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sm, boundSym := e.symMap.lookupBinding(sym)
+	if sm == nil {
+		return nil, ErrNotBound(sym)
+	}
+	val, err := Evaluate(e, args[1])
+	if err != nil {
+		return nil, err
+	}
+	sm.Set(boundSym, val)
+	return val, nil
+}
+
+// (LAMBDA params body...) creates a closure.
+func lambdaFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	return newClosure(e, args[0], args[1:])
+}
+
+func newClosure(e *Engine, spec Value, body []Value) (*Closure, error) {
+	params, rest, err := parseParams(spec)
+	if err != nil {
+		return nil, err
+	}
+	c := &Closure{params: params, rest: rest, body: body, env: e}
+	if len(body) > 1 {
+		if doc, isString := body[0].(*String); isString {
+			c.doc, c.body = doc.GetValue(), body[1:]
+		}
+	}
+	return c, nil
+}
+
+// (LET ((sym expr)...) body...) evaluates all expressions, binds them to
+// their symbols in a new scope, and evaluates the body there.
+func letFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := GetSequence(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.state.allocate(len(bindings.GetSlice()) + 1); err != nil {
+		return nil, err
+	}
+	scope := e.newLocal()
+	for _, binding := range bindings.GetSlice() {
+		sym, val, err2 := parseBinding(e, binding)
+		if err2 != nil {
+			return nil, err2
+		}
+		scope.Define(sym, val)
+	}
+	return evaluateBody(scope, args[1:])
+}
+
+func parseBinding(e *Engine, binding Value) (*Symbol, Value, error) {
+	if sym, ok := binding.(*Symbol); ok {
+		return sym, Nil(), nil
+	}
+	seq, ok := binding.(Sequence)
+	if !ok {
+		return nil, nil, fmt.Errorf("LET binding %v is not a sequence", binding)
+	}
+	vals := seq.GetSlice()
+	if len(vals) != 2 {
+		return nil, nil, fmt.Errorf("LET binding %v must have two elements", binding)
+	}
+	sym, err := GetSymbol(vals, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	val, err := Evaluate(e, vals[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return sym, val, nil
+}
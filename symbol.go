@@ -10,47 +10,218 @@
 
 package sxpf
 
-import "strings"
+import (
+	"strings"
+	"sync"
+)
 
 // Symbol is a value that identifies something.
 type Symbol struct {
-	val string
+	val        string
+	origin     *symbolOrigin // not nil, if symbol was renamed by a macro expansion
+	canonical  *Symbol       // not nil, if symbol shares its bindings with another symbol
+	sensitive  bool          // true, if case is significant for Equal
+	ns         *Namespace    // not nil, if symbol belongs to a namespace
+	uninterned bool          // true, if symbol is only equal to itself
+	home       *symbolHome   // not nil, if symbol is transient
 }
 
 // GetValue returns the string value of the symbol.
 func (sym *Symbol) GetValue() string { return sym.val }
 
 // Equal retruns true if the other value is equal to this one.
+//
+// Symbols that share their bindings are equal. An uninterned symbol is only
+// equal to itself. Symbols of different namespaces are not equal. Otherwise
+// the names are compared. The comparison ignores case, unless one of the
+// symbols was created by a case-sensitive SymbolTable.
 func (sym *Symbol) Equal(other Value) bool {
 	if sym == nil || other == nil {
 		return sym == other
 	}
 	if o, ok := other.(*Symbol); ok {
+		if sym.key() == o.key() {
+			return true
+		}
+		if sym.uninterned || o.uninterned || sym.ns != o.ns {
+			return false
+		}
+		if sym.sensitive || o.sensitive {
+			return sym.val == o.val
+		}
 		return strings.EqualFold(sym.val, o.val)
 	}
 	return false
 }
 
-func (sym *Symbol) String() string { return sym.val }
-func (sym *Symbol) Value() string  { return sym.val }
+// String returns the name of the symbol. If the symbol belongs to a
+// namespace other than the default namespace, the name is qualified by the
+// name of the namespace, e.g. "NS/NAME". The name of an uninterned symbol is
+// preceded by "#:".
+func (sym *Symbol) String() string {
+	if sym.uninterned {
+		return "#:" + sym.val
+	}
+	if ns := sym.ns; ns != nil && ns.qualify {
+		return ns.name + "/" + sym.val
+	}
+	return sym.val
+}
+func (sym *Symbol) Value() string { return sym.val }
+
+// key returns the symbol that is used to bind a value to the symbol.
+func (sym *Symbol) key() *Symbol {
+	for {
+		if sym.canonical != nil {
+			sym = sym.canonical
+		} else if sym.home == nil {
+			return sym
+		} else if stored := sym.home.stored(); stored != nil {
+			sym = stored
+		} else {
+			return sym
+		}
+	}
+}
+
+// CasePolicy defines how a SymbolTable handles the case of symbol names.
+type CasePolicy int
+
+// Constants for CasePolicy.
+const (
+	// CaseFolding converts all names to upper case. "Foo" is printed as "FOO".
+	CaseFolding CasePolicy = iota
+
+	// CasePreserving keeps the spelling of all names, but ignores case on
+	// lookup. "Foo" is printed as "Foo", but it is equal to "FOO" and names
+	// the same binding.
+	CasePreserving
+
+	// CaseSensitive keeps the spelling of all names, and symbols that differ
+	// in case are different. "Foo" and "FOO" are not equal.
+	CaseSensitive
+)
 
 // SymbolTable allows to create unique symbols.
+//
+// A SymbolTable may be used by multiple goroutines concurrently. Looking up
+// an already created symbol does not acquire a lock. Copies of a SymbolTable
+// share their symbols.
 type SymbolTable struct {
-	m map[string]*Symbol
+	tab *symbolTable
+}
+
+type symbolTable struct {
+	m      sync.Map           // maps names to symbols
+	mx     sync.Mutex         // serializes the creation of symbols
+	folded map[string]*Symbol // canonical symbols for policy CasePreserving
+	policy CasePolicy
+	ns     *Namespace // namespace of all created symbols
 }
 
+// NewSymbolTable creates a new symbol table with policy CaseFolding.
 func NewSymbolTable() SymbolTable {
-	return SymbolTable{map[string]*Symbol{}}
+	return NewSymbolTableWithPolicy(CaseFolding)
+}
+
+// NewSymbolTableWithPolicy creates a new symbol table with the given case
+// policy.
+func NewSymbolTableWithPolicy(policy CasePolicy) SymbolTable {
+	return newSymbolTable(policy, nil)
+}
+
+func newSymbolTable(policy CasePolicy, ns *Namespace) SymbolTable {
+	tab := &symbolTable{policy: policy, ns: ns}
+	if policy == CasePreserving {
+		tab.folded = map[string]*Symbol{}
+	}
+	return SymbolTable{tab}
+}
+
+// CasePolicy returns the case policy of the symbol table.
+func (st *SymbolTable) CasePolicy() CasePolicy { return st.tab.policy }
+
+// casePolicyOf returns the case policy of the symbol maker. Names of other
+// symbol makers are assumed to be converted to upper case.
+func casePolicyOf(smk SymbolMaker) CasePolicy {
+	switch m := smk.(type) {
+	case *SymbolTable:
+		return m.CasePolicy()
+	case *trivialSymbolMaker:
+		return m.symbols.CasePolicy()
+	case *Namespaces:
+		return m.policy
+	case *Namespace:
+		return m.symbols.CasePolicy()
+	case *TransientSymbols:
+		return casePolicyOf(m.base)
+	case *Engine:
+		return casePolicyOf(m.smk)
+	case *BasicEnvironment:
+		return casePolicyOf(m.smk)
+	case *vmEnv:
+		return casePolicyOf(m.e.smk)
+	}
+	return CaseFolding
 }
 
 func (st *SymbolTable) MakeSymbol(s string) *Symbol {
 	if s == "" {
 		return nil
 	}
-	sym, found := st.m[s]
-	if !found {
-		sym = &Symbol{strings.ToUpper(s)}
-		st.m[s] = sym
+	tab := st.tab
+	if tab.policy == CaseFolding {
+		s = strings.ToUpper(s)
+	}
+	if sym, found := tab.m.Load(s); found {
+		return sym.(*Symbol)
+	}
+
+	tab.mx.Lock()
+	defer tab.mx.Unlock()
+	if sym, found := tab.m.Load(s); found {
+		return sym.(*Symbol)
+	}
+	sym := tab.newSymbol(s)
+	if tab.policy == CasePreserving {
+		folded := strings.ToUpper(s)
+		if canonical, hasCanonical := tab.folded[folded]; hasCanonical {
+			sym.canonical = canonical
+		} else {
+			tab.folded[folded] = sym
+		}
 	}
+	tab.m.Store(s, sym)
 	return sym
 }
+
+func (tab *symbolTable) newSymbol(s string) *Symbol {
+	return &Symbol{val: s, sensitive: tab.policy == CaseSensitive, ns: tab.ns}
+}
+
+// findSymbol returns the symbol with the given name, if it was already
+// created. Otherwise, it returns a new transient symbol, that is not stored
+// in the table.
+func (st *SymbolTable) findSymbol(s string) (*Symbol, bool) {
+	if s == "" {
+		return nil, false
+	}
+	tab := st.tab
+	if tab.policy == CaseFolding {
+		s = strings.ToUpper(s)
+	}
+	if sym, found := tab.m.Load(s); found {
+		return sym.(*Symbol), true
+	}
+	if tab.policy == CasePreserving {
+		tab.mx.Lock()
+		_, hasCanonical := tab.folded[strings.ToUpper(s)]
+		tab.mx.Unlock()
+		if hasCanonical {
+			return st.MakeSymbol(s), true
+		}
+	}
+	sym := tab.newSymbol(s)
+	sym.home = &symbolHome{smk: st, name: s}
+	return sym, false
+}
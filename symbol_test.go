@@ -0,0 +1,131 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+)
+
+func TestSymbolCasePolicy(t *testing.T) {
+	testcases := []struct {
+		policy sxpf.CasePolicy
+		str    string // String() of "Foo"
+		equal  bool   // "Foo" equal to "FOO"
+		same   bool   // "Foo" identical to "FOO"
+		eval   string // result of evaluating "(BEGIN (DEFINE Foo 1) FOO)"
+	}{
+		{sxpf.CaseFolding, "FOO", true, true, "1"},
+		{sxpf.CasePreserving, "Foo", true, false, "1"},
+		{sxpf.CaseSensitive, "Foo", false, false, `symbol "FOO" not bound`},
+	}
+	for i, tc := range testcases {
+		smk := sxpf.NewSymbolMaker(tc.policy)
+		foo, fooUpper := smk.MakeSymbol("Foo"), smk.MakeSymbol("FOO")
+		if got := foo.String(); got != tc.str {
+			t.Errorf("%d: Foo should be printed as %q, but got %q", i, tc.str, got)
+		}
+		if got := foo.Equal(fooUpper); got != tc.equal {
+			t.Errorf("%d: Foo.Equal(FOO) should be %v, but got %v", i, tc.equal, got)
+		}
+		if got := foo.Equal(fooUpper); got != fooUpper.Equal(foo) {
+			t.Errorf("%d: Equal must be symmetric", i)
+		}
+		if got := foo == fooUpper; got != tc.same {
+			t.Errorf("%d: Foo == FOO should be %v, but got %v", i, tc.same, got)
+		}
+		if smk.MakeSymbol("Foo") != foo {
+			t.Errorf("%d: symbols with same spelling must be identical", i)
+		}
+
+		engine := sxpf.NewEngine(smk)
+		expr, err := sxpf.ParseString(engine, "(BEGIN (DEFINE Foo 1) FOO)")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var got string
+		if val, err2 := engine.Eval(expr); err2 != nil {
+			got = err2.Error()
+		} else {
+			got = val.String()
+		}
+		if got != tc.eval {
+			t.Errorf("%d: evaluation should result in %q, but got %q", i, tc.eval, got)
+		}
+	}
+}
+
+func TestSymbolTableConcurrent(t *testing.T) {
+	const numWorkers, numSymbols = 8, 200
+	smk := sxpf.NewSymbolMaker(sxpf.CasePreserving)
+	results := make([][]*sxpf.Symbol, numWorkers)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			syms := make([]*sxpf.Symbol, 2*numSymbols)
+			for i := 0; i < numSymbols; i++ {
+				syms[2*i] = smk.MakeSymbol("sym" + strconv.Itoa(i))
+				syms[2*i+1] = smk.MakeSymbol("SYM" + strconv.Itoa(i))
+			}
+			results[w] = syms
+		}(w)
+	}
+	wg.Wait()
+	for w := 1; w < numWorkers; w++ {
+		for i, sym := range results[w] {
+			if sym != results[0][i] {
+				t.Errorf("worker %d got a different symbol for %v", w, sym)
+			}
+		}
+	}
+	for i := 0; i < numSymbols; i++ {
+		if sym, symUpper := results[0][2*i], results[0][2*i+1]; !sym.Equal(symUpper) {
+			t.Errorf("%v should be equal to %v", sym, symUpper)
+		}
+	}
+}
+
+func TestEngineConcurrent(t *testing.T) {
+	const numWorkers = 8
+	smk := sxpf.NewTrivialSymbolMaker()
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			engine := sxpf.NewEngine(smk)
+			src := "(BEGIN (DEFINE (f x) (IF x (QUOTE a) (QUOTE b))) (f ()))"
+			for i := 0; i < 100; i++ {
+				expr, err := sxpf.ParseString(engine, src)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				val, err := engine.Eval(expr)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got := val.String(); got != "B" {
+					t.Errorf("worker %d: expected B, but got %v", w, got)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,358 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import "fmt"
+
+// syntaxRules is a macro transformer, that matches the arguments of a macro
+// call against a list of patterns. The template of the first matching
+// pattern is instantiated with the matched values.
+//
+// Symbols introduced by a template are renamed. A renamed symbol is
+// different to all other symbols, so it cannot capture a symbol of the macro
+// call. If a renamed symbol is not bound when it is looked up, its original
+// symbol is looked up in the scope of the macro definition.
+type syntaxRules struct {
+	scope    *SymbolMap
+	literals []*Symbol
+	rules    []syntaxRule
+}
+
+type syntaxRule struct {
+	params   []Value // pattern without the leading macro keyword
+	tail     Value   // if pattern is a dotted list
+	template Value
+}
+
+// symbolOrigin stores the original symbol of a renamed symbol, together with
+// the scope it must be looked up.
+type symbolOrigin struct {
+	sym   *Symbol
+	scope *SymbolMap
+}
+
+func (sym *Symbol) getOrigin() *symbolOrigin {
+	if sym == nil {
+		return nil
+	}
+	return sym.origin
+}
+
+// srMatch is the value matched by a pattern variable. If the variable was
+// followed by an ellipsis, there is one sub-match for every repetition.
+type srMatch struct {
+	val   Value
+	seq   bool
+	items []*srMatch
+}
+
+type srBindings map[*Symbol]*srMatch
+
+// (SYNTAX-RULES (literal...) (pattern template)...) creates a macro.
+func syntaxRulesFn(env Environment, args []Value) (Value, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, err
+	}
+	lits, err := GetSequence(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sr := &syntaxRules{scope: e.symMap}
+	for _, lit := range lits.GetSlice() {
+		sym, ok := lit.(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("SYNTAX-RULES literal %v is not a symbol", lit)
+		}
+		sr.literals = append(sr.literals, sym)
+	}
+	for _, arg := range args[1:] {
+		rule, err2 := parseSyntaxRule(arg)
+		if err2 != nil {
+			return nil, err2
+		}
+		sr.rules = append(sr.rules, rule)
+	}
+	return NewMacro("", sr.transform), nil
+}
+
+func parseSyntaxRule(val Value) (syntaxRule, error) {
+	seq, ok := val.(Sequence)
+	if !ok || len(seq.GetSlice()) != 2 {
+		return syntaxRule{}, fmt.Errorf("SYNTAX-RULES rule %v must be a sequence of pattern and template", val)
+	}
+	vals := seq.GetSlice()
+	var params []Value
+	var tail Value
+	switch pattern := vals[0].(type) {
+	case *Pair:
+		params, tail = pattern.getElems()
+	case *Vector:
+		params = pattern.GetSlice()
+	}
+	if len(params) == 0 {
+		return syntaxRule{}, fmt.Errorf("SYNTAX-RULES pattern %v must be a non-empty sequence", vals[0])
+	}
+	return syntaxRule{params: params[1:], tail: tail, template: vals[1]}, nil
+}
+
+func (sr *syntaxRules) transform(_ Environment, args []Value) (Value, error) {
+	for _, rule := range sr.rules {
+		b := srBindings{}
+		if sr.matchElems(rule.params, rule.tail, args, nil, b) {
+			return sr.instantiate(rule.template, b, map[*Symbol]*Symbol{})
+		}
+	}
+	return nil, fmt.Errorf("no syntax rule matches %v", NewPairFromSlice(args))
+}
+
+func isEllipsis(val Value) bool {
+	sym, ok := val.(*Symbol)
+	return ok && sym.GetValue() == "..."
+}
+
+func isWildcard(val Value) bool {
+	sym, ok := val.(*Symbol)
+	return ok && sym.GetValue() == "_"
+}
+
+func (sr *syntaxRules) isLiteral(sym *Symbol) bool {
+	for _, lit := range sr.literals {
+		if lit.Equal(sym) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sr *syntaxRules) match(pattern, form Value, b srBindings) bool {
+	switch pat := pattern.(type) {
+	case *Symbol:
+		if sr.isLiteral(pat) {
+			return pat.Equal(form)
+		}
+		if !isWildcard(pat) {
+			b[pat.key()] = &srMatch{val: form}
+		}
+		return true
+	case *Pair:
+		fp, ok := form.(*Pair)
+		if !ok {
+			return false
+		}
+		pElems, pTail := pat.getElems()
+		fElems, fTail := fp.getElems()
+		return sr.matchElems(pElems, pTail, fElems, fTail, b)
+	case *Vector:
+		fv, ok := form.(*Vector)
+		if !ok {
+			return false
+		}
+		return sr.matchElems(pat.GetSlice(), nil, fv.GetSlice(), nil, b)
+	}
+	return pattern.Equal(form)
+}
+
+func (sr *syntaxRules) matchElems(pElems []Value, pTail Value, fElems []Value, fTail Value, b srBindings) bool {
+	ell := -1
+	for i := 0; i+1 < len(pElems); i++ {
+		if isEllipsis(pElems[i+1]) {
+			ell = i
+			break
+		}
+	}
+	if ell < 0 {
+		if len(fElems) < len(pElems) {
+			return false
+		}
+		for i, pat := range pElems {
+			if !sr.match(pat, fElems[i], b) {
+				return false
+			}
+		}
+		rest := fElems[len(pElems):]
+		if pTail == nil {
+			return len(rest) == 0 && fTail == nil
+		}
+		return sr.match(pTail, newDottedList(rest, fTail), b)
+	}
+
+	after := pElems[ell+2:]
+	n := len(fElems) - ell - len(after)
+	if n < 0 {
+		return false
+	}
+	for i := 0; i < ell; i++ {
+		if !sr.match(pElems[i], fElems[i], b) {
+			return false
+		}
+	}
+	rep := pElems[ell]
+	vars := sr.patternVars(rep, nil)
+	items := make(map[*Symbol][]*srMatch, len(vars))
+	for k := 0; k < n; k++ {
+		bk := srBindings{}
+		if !sr.match(rep, fElems[ell+k], bk) {
+			return false
+		}
+		for _, v := range vars {
+			items[v] = append(items[v], bk[v])
+		}
+	}
+	for _, v := range vars {
+		b[v] = &srMatch{seq: true, items: items[v]}
+	}
+	for i, pat := range after {
+		if !sr.match(pat, fElems[ell+n+i], b) {
+			return false
+		}
+	}
+	if pTail == nil {
+		return fTail == nil
+	}
+	return sr.match(pTail, newDottedList(nil, fTail), b)
+}
+
+// patternVars returns all pattern variables of the given pattern.
+func (sr *syntaxRules) patternVars(pattern Value, vars []*Symbol) []*Symbol {
+	switch pat := pattern.(type) {
+	case *Symbol:
+		if !sr.isLiteral(pat) && !isWildcard(pat) && !isEllipsis(pat) {
+			vars = append(vars, pat.key())
+		}
+	case *Pair:
+		elems, tail := pat.getElems()
+		for _, elem := range elems {
+			vars = sr.patternVars(elem, vars)
+		}
+		if tail != nil {
+			vars = sr.patternVars(tail, vars)
+		}
+	case *Vector:
+		for _, elem := range pat.GetSlice() {
+			vars = sr.patternVars(elem, vars)
+		}
+	}
+	return vars
+}
+
+func (sr *syntaxRules) instantiate(tmpl Value, b srBindings, renames map[*Symbol]*Symbol) (Value, error) {
+	switch t := tmpl.(type) {
+	case *Symbol:
+		if m, found := b[t.key()]; found {
+			if m.seq {
+				return nil, fmt.Errorf("pattern variable %v must be followed by an ellipsis", t)
+			}
+			return m.val, nil
+		}
+		if r, found := renames[t]; found {
+			return r, nil
+		}
+		r := &Symbol{val: t.val, origin: &symbolOrigin{sym: t, scope: sr.scope}, sensitive: t.sensitive, ns: t.ns}
+		renames[t] = r
+		return r, nil
+	case *Pair:
+		elems, tail := t.getElems()
+		res, err := sr.instantiateElems(elems, b, renames)
+		if err != nil {
+			return nil, err
+		}
+		if tail != nil {
+			if tail, err = sr.instantiate(tail, b, renames); err != nil {
+				return nil, err
+			}
+		}
+		return newDottedList(res, tail), nil
+	case *Vector:
+		res, err := sr.instantiateElems(t.GetSlice(), b, renames)
+		if err != nil {
+			return nil, err
+		}
+		return NewVector(res...), nil
+	}
+	return tmpl, nil
+}
+
+func (sr *syntaxRules) instantiateElems(elems []Value, b srBindings, renames map[*Symbol]*Symbol) ([]Value, error) {
+	res := make([]Value, 0, len(elems))
+	for i := 0; i < len(elems); i++ {
+		if i+1 < len(elems) && isEllipsis(elems[i+1]) {
+			vals, err := sr.instantiateEllipsis(elems[i], b, renames)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, vals...)
+			i++
+			continue
+		}
+		val, err := sr.instantiate(elems[i], b, renames)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, val)
+	}
+	return res, nil
+}
+
+func (sr *syntaxRules) instantiateEllipsis(tmpl Value, b srBindings, renames map[*Symbol]*Symbol) ([]Value, error) {
+	var vars []*Symbol
+	for _, sym := range templateSymbols(tmpl, nil) {
+		if m, found := b[sym]; found && m.seq {
+			vars = append(vars, sym)
+		}
+	}
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("no pattern variable before ellipsis in template %v", tmpl)
+	}
+	n := len(b[vars[0]].items)
+	for _, v := range vars[1:] {
+		if len(b[v].items) != n {
+			return nil, fmt.Errorf("pattern variables %v and %v match a different number of values", vars[0], v)
+		}
+	}
+	res := make([]Value, 0, n)
+	for k := 0; k < n; k++ {
+		bk := make(srBindings, len(b))
+		for sym, m := range b {
+			bk[sym] = m
+		}
+		for _, v := range vars {
+			bk[v] = b[v].items[k]
+		}
+		val, err := sr.instantiate(tmpl, bk, renames)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, val)
+	}
+	return res, nil
+}
+
+// templateSymbols returns all symbols of the given template.
+func templateSymbols(tmpl Value, syms []*Symbol) []*Symbol {
+	switch t := tmpl.(type) {
+	case *Symbol:
+		syms = append(syms, t.key())
+	case *Pair:
+		elems, tail := t.getElems()
+		for _, elem := range elems {
+			syms = templateSymbols(elem, syms)
+		}
+		if tail != nil {
+			syms = templateSymbols(tail, syms)
+		}
+	case *Vector:
+		for _, elem := range t.GetSlice() {
+			syms = templateSymbols(elem, syms)
+		}
+	}
+	return syms
+}
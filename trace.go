@@ -0,0 +1,346 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// TraceEvent describes a form call, that is observed by a Tracer or a
+// BreakHandler.
+type TraceEvent struct {
+	Env    Environment // the environment of the call
+	Depth  int         // number of enclosing traced calls
+	Expr   Value       // the expression of the call, or nil if the form was applied
+	Form   Form
+	Args   []Value // the arguments; unevaluated, if the form is special
+	Result Value   // the result, after the call returned
+	Err    error   // the error, if the call failed
+}
+
+// Tracer observes the calls of traced forms (see Engine.Trace).
+type Tracer interface {
+	// Enter is called before the form is called.
+	Enter(*TraceEvent)
+
+	// Exit is called after the form returned a result.
+	Exit(*TraceEvent)
+
+	// Error is called after the form failed.
+	Error(*TraceEvent)
+}
+
+// BreakHandler is called before a form with a breakpoint is called (see
+// Engine.Break), and by the BREAK form. The evaluation pauses until it
+// returns. The environment of the event allows to inspect the current
+// scope, e.g. by evaluating a symbol in it. If the handler returns an error,
+// the call fails with this error.
+type BreakHandler func(*TraceEvent) error
+
+// SetTracer sets the tracer of the engine and returns the previous one. If
+// it is nil, calls are not traced. By default, an engine has no tracer.
+func (e *Engine) SetTracer(t Tracer) Tracer {
+	prevT := e.state.tracer
+	e.state.tracer = t
+	return prevT
+}
+
+// SetBreakHandler sets the handler of breakpoints and returns the previous
+// one. If it is nil, breakpoints are ignored.
+func (e *Engine) SetBreakHandler(h BreakHandler) BreakHandler {
+	prevH := e.state.breakHandler
+	e.state.breakHandler = h
+	return prevH
+}
+
+// Trace the calls of the given forms. If no form is given, all calls are
+// traced.
+//
+// A call of a traced form in tail position remains a tail call. Its result is
+// reported, when the tail call is resolved. Until then, the depth of the call
+// is not decreased. Compiled code does not call the forms it translates into
+// instructions, like IF or LET, so these calls are not traced.
+func (e *Engine) Trace(forms ...Form) {
+	st := e.state
+	st.traced, st.traceAll = addForms(st.traced, st.traceAll, forms)
+}
+
+// Untrace stops tracing the given forms. If no form is given, no call is
+// traced anymore.
+func (e *Engine) Untrace(forms ...Form) {
+	st := e.state
+	st.traced, st.traceAll = removeForms(st.traced, st.traceAll, forms)
+}
+
+// Traced returns the forms, that were explicitly traced.
+func (e *Engine) Traced() []Form { return append([]Form(nil), e.state.traced...) }
+
+// Break sets a breakpoint on the given forms. If no form is given, all
+// calls stop at a breakpoint.
+func (e *Engine) Break(forms ...Form) {
+	st := e.state
+	st.breakpoints, st.breakAll = addForms(st.breakpoints, st.breakAll, forms)
+}
+
+// Unbreak removes the breakpoints of the given forms. If no form is given,
+// all breakpoints are removed.
+func (e *Engine) Unbreak(forms ...Form) {
+	st := e.state
+	st.breakpoints, st.breakAll = removeForms(st.breakpoints, st.breakAll, forms)
+}
+
+// addForms adds the forms to the list. Forms that cannot be compared are
+// ignored. If no form is given, all forms are selected.
+func addForms(list []Form, all bool, forms []Form) ([]Form, bool) {
+	if len(forms) == 0 {
+		return list, true
+	}
+	for _, form := range forms {
+		if form != nil && reflect.TypeOf(form).Comparable() && indexForm(list, form) < 0 {
+			list = append(list, form)
+		}
+	}
+	return list, all
+}
+
+// removeForms removes the forms from the list. If no form is given, the
+// list is cleared and no form is selected.
+func removeForms(list []Form, all bool, forms []Form) ([]Form, bool) {
+	if len(forms) == 0 {
+		return nil, false
+	}
+	for _, form := range forms {
+		if i := indexForm(list, form); i >= 0 {
+			list = append(list[:i:i], list[i+1:]...)
+		}
+	}
+	return list, all
+}
+
+func indexForm(list []Form, form Form) int {
+	for i, f := range list {
+		if f == form {
+			return i
+		}
+	}
+	return -1
+}
+
+// TraceForms are the forms TRACE, UNTRACE, and BREAK. They are not bound by
+// NewEngine. A host, that allows evaluated values to trace calls, binds them
+// with Engine.BindBuiltin.
+var TraceForms = []*Builtin{
+	NewBuiltin("TRACE", false, 0, -1, traceFn).WithDoc("(TRACE form...) traces the calls of the forms, or of all forms."),
+	NewBuiltin("UNTRACE", false, 0, -1, untraceFn).WithDoc("(UNTRACE form...) stops tracing the forms, or all forms."),
+	NewBuiltin("BREAK", true, 0, 0, breakFn).WithDoc("(BREAK) pauses the evaluation and calls the break handler."),
+}
+
+// breakForm is the builtin of the BREAK form.
+var breakForm *Builtin
+
+func init() {
+	for _, b := range TraceForms {
+		if b.name == "BREAK" {
+			breakForm = b
+		}
+	}
+}
+
+// stateOf returns the evaluation state of the given environment, or nil if
+// it does not belong to an Engine.
+func stateOf(env Environment) *engineState {
+	switch e := env.(type) {
+	case *Engine:
+		return e.state
+	case *vmEnv:
+		return e.e.state
+	}
+	return nil
+}
+
+// callForm calls the form with the given arguments. If the form is traced or
+// has a breakpoint, the call is reported.
+func callForm(env Environment, expr Value, form Form, args []Value) (Value, error) {
+	if st := stateOf(env); st != nil && st.observes() {
+		return st.call(env, expr, form, args)
+	}
+	return form.Call(env, args)
+}
+
+// observes returns true, if some form calls are traced or have breakpoints.
+func (st *engineState) observes() bool {
+	return st.traceAll || st.breakAll || len(st.traced) > 0 || len(st.breakpoints) > 0
+}
+
+// call calls the form and reports the call to the tracer and the break
+// handler, if needed.
+func (st *engineState) call(env Environment, expr Value, form Form, args []Value) (Value, error) {
+	tracer := st.tracer
+	if tracer != nil && !st.traceAll && indexForm(st.traced, form) < 0 {
+		tracer = nil
+	}
+	handler := st.breakHandler
+	if handler != nil && !st.breakAll && indexForm(st.breakpoints, form) < 0 {
+		handler = nil
+	}
+	if tracer == nil && handler == nil {
+		return form.Call(env, args)
+	}
+	ev := TraceEvent{Env: env, Depth: st.traceDepth, Expr: expr, Form: form, Args: args}
+	if tracer != nil {
+		tracer.Enter(&ev)
+	}
+	if handler != nil {
+		if err := handler(&ev); err != nil {
+			return nil, st.traceResult(tracer, &ev, nil, err)
+		}
+	}
+	if tracer == nil {
+		return form.Call(env, args)
+	}
+	st.traceDepth++
+	res, err := form.Call(env, args)
+	exit := &traceExit{st: st, tracer: tracer, ev: &ev}
+	if tc, isTailCall := res.(*tailCall); isTailCall && err == nil {
+		exits := append([]*traceExit{exit}, tc.exits...)
+		return &tailCall{env: tc.env, val: tc.val, form: tc.form, args: tc.args, exits: exits}, nil
+	}
+	return res, exit.report(res, err)
+}
+
+// traceExit is a traced call, whose result is not yet reported.
+type traceExit struct {
+	st     *engineState
+	tracer Tracer
+	ev     *TraceEvent
+}
+
+// report the result of the traced call.
+func (te *traceExit) report(res Value, err error) error {
+	te.st.traceDepth--
+	return te.st.traceResult(te.tracer, te.ev, res, err)
+}
+
+// traceResult reports the result of a call to the tracer, if it is not nil.
+func (*engineState) traceResult(tracer Tracer, ev *TraceEvent, res Value, err error) error {
+	if tracer == nil {
+		return err
+	}
+	if err != nil {
+		ev.Err = err
+		tracer.Error(ev)
+	} else {
+		ev.Result = res
+		tracer.Exit(ev)
+	}
+	return err
+}
+
+// NewWriterTracer creates a tracer, that writes every call and its result
+// to the given writer, indented by the depth of the call.
+func NewWriterTracer(w io.Writer) Tracer { return &writerTracer{w} }
+
+type writerTracer struct {
+	w io.Writer
+}
+
+func (wt *writerTracer) Enter(ev *TraceEvent) {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	sb.WriteString(formName(ev))
+	for _, arg := range ev.Args {
+		sb.WriteByte(' ')
+		sb.WriteString(arg.String())
+	}
+	sb.WriteByte(')')
+	wt.write(ev, sb.String())
+}
+func (wt *writerTracer) Exit(ev *TraceEvent)  { wt.write(ev, "=> "+ev.Result.String()) }
+func (wt *writerTracer) Error(ev *TraceEvent) { wt.write(ev, "!! "+ev.Err.Error()) }
+
+func (wt *writerTracer) write(ev *TraceEvent, s string) {
+	fmt.Fprintf(wt.w, "%s%s\n", strings.Repeat("  ", ev.Depth), s)
+}
+
+// formName returns the name of the called form, preferably the symbol that
+// denotes it in the call.
+func formName(ev *TraceEvent) string {
+	if p, isPair := ev.Expr.(*Pair); isPair && p != nil {
+		if sym, isSymbol := p.GetFirst().(*Symbol); isSymbol {
+			return sym.GetValue()
+		}
+	}
+	if n, ok := ev.Form.(interface{ Name() string }); ok && n.Name() != "" {
+		return n.Name()
+	}
+	return ev.Form.String()
+}
+
+// (TRACE form...) traces the calls of the given forms, or of all forms, if
+// none is given. It returns the list of explicitly traced forms.
+func traceFn(env Environment, args []Value) (Value, error) {
+	e, forms, err := getEngineForms(env, args)
+	if err != nil {
+		return nil, err
+	}
+	e.Trace(forms...)
+	return formList(e.state.traced), nil
+}
+
+// (UNTRACE form...) stops tracing the given forms, or all forms, if none is
+// given. It returns the list of forms, that are still traced.
+func untraceFn(env Environment, args []Value) (Value, error) {
+	e, forms, err := getEngineForms(env, args)
+	if err != nil {
+		return nil, err
+	}
+	e.Untrace(forms...)
+	return formList(e.state.traced), nil
+}
+
+func getEngineForms(env Environment, args []Value) (*Engine, []Form, error) {
+	e, err := getEngine(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	forms := make([]Form, len(args))
+	for i := range args {
+		if forms[i], err = GetForm(args, i); err != nil {
+			return nil, nil, err
+		}
+	}
+	return e, forms, nil
+}
+
+func formList(forms []Form) *Pair {
+	vals := make([]Value, len(forms))
+	for i, form := range forms {
+		vals[i] = form
+	}
+	return NewPairFromSlice(vals)
+}
+
+// (BREAK) pauses the evaluation and calls the break handler of the engine
+// with the current environment.
+func breakFn(env Environment, _ []Value) (Value, error) {
+	st := stateOf(env)
+	if st == nil || st.breakHandler == nil {
+		return Nil(), nil
+	}
+	ev := TraceEvent{Env: env, Depth: st.traceDepth, Form: breakForm}
+	if err := st.breakHandler(&ev); err != nil {
+		return nil, err
+	}
+	return Nil(), nil
+}
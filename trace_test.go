@@ -0,0 +1,182 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+	"github.com/t73fde/sxpf/builtins"
+)
+
+const srcFact = "(DEFINE (fact n) (IF (= n 0) 1 (* n (fact (- n 1)))))"
+
+// newTraceTestEngine creates an engine, that binds the trace forms.
+func newTraceTestEngine() *sxpf.Engine {
+	engine := newCompileTestEngine()
+	builtins.Register(engine, sxpf.TraceForms)
+	return engine
+}
+
+// runTraced evaluates the source, either interpreted or compiled.
+func runTraced(engine *sxpf.Engine, src string, compiled bool) (sxpf.Value, error) {
+	if compiled {
+		return runCompiled(engine, src)
+	}
+	expr, err := sxpf.ParseString(engine, src)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Eval(expr)
+}
+
+func TestTrace(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(BEGIN (TRACE fact) (fact 3))",
+			"(FACT 3)\n  (FACT 2)\n    (FACT 1)\n      (FACT 0)\n      => 1\n    => 1\n  => 2\n=> 6\n"},
+		{"(BEGIN (TRACE fact) (UNTRACE fact) (fact 3))", ""},
+		{"(BEGIN (TRACE fact) (UNTRACE) (fact 3))", ""},
+		{"(BEGIN (TRACE fact *) (fact 1))",
+			"(FACT 1)\n  (FACT 0)\n  => 1\n  (* 1 1)\n  => 1\n=> 1\n"},
+		{"(BEGIN (TRACE fact) (APPLY fact (LIST 1)))", "(FACT 1)\n  (FACT 0)\n  => 1\n=> 1\n"},
+		{"(BEGIN (TRACE fact) (fact (QUOTE a)))", "(FACT A)\n!! A / 0 is not an integer\n"},
+	}
+	for _, compiled := range []bool{false, true} {
+		for i, tc := range testcases {
+			engine := newTraceTestEngine()
+			var sb strings.Builder
+			engine.SetTracer(sxpf.NewWriterTracer(&sb))
+			if _, err := runTraced(engine, srcFact, compiled); err != nil {
+				t.Fatal(err)
+			}
+			runTraced(engine, tc.src, compiled)
+			if got := sb.String(); got != tc.exp {
+				t.Errorf("%d/%v: %v should trace\n%v\nbut got\n%v", i, compiled, tc.src, tc.exp, got)
+			}
+		}
+	}
+}
+
+func TestTraceDefault(t *testing.T) {
+	engine := newCompileTestEngine()
+	if tracer := engine.SetTracer(nil); tracer != nil {
+		t.Errorf("an engine should have no tracer by default, but got %v", tracer)
+	}
+	for _, name := range []string{"TRACE", "UNTRACE", "BREAK"} {
+		if _, found := engine.SymbolMap().Lookup(engine.MakeSymbol(name)); found {
+			t.Errorf("%v should not be bound by default", name)
+		}
+	}
+}
+
+// countTracer counts the reported calls.
+type countTracer struct{ enter, exit, err int }
+
+func (ct *countTracer) Enter(*sxpf.TraceEvent) { ct.enter++ }
+func (ct *countTracer) Exit(*sxpf.TraceEvent)  { ct.exit++ }
+func (ct *countTracer) Error(*sxpf.TraceEvent) { ct.err++ }
+
+func TestTraceTailCall(t *testing.T) {
+	const srcLoop = "(DEFINE (loop n) (IF (= n 0) (QUOTE done) (loop (- n 1))))"
+	for _, compiled := range []bool{false, true} {
+		for _, traced := range []bool{false, true} {
+			engine := newTraceTestEngine()
+			var ct countTracer
+			engine.SetTracer(&ct)
+			if _, err := runTraced(engine, srcLoop, compiled); err != nil {
+				t.Fatal(err)
+			}
+			src := "(loop 20000)"
+			if traced {
+				src = "(BEGIN (TRACE) (loop 20000))"
+			}
+			val, err := runTraced(engine, src, compiled)
+			if err != nil || val.String() != "DONE" {
+				t.Errorf("%v/%v: %v should result in DONE, but got %v / %v", compiled, traced, src, val, err)
+				continue
+			}
+			if traced && (ct.enter == 0 || ct.enter != ct.exit || ct.err != 0) {
+				t.Errorf("%v: every traced call should exit, but got %+v", compiled, ct)
+			}
+		}
+	}
+}
+
+func TestTraceResult(t *testing.T) {
+	engine := newTraceTestEngine()
+	if _, err := runTraced(engine, srcFact, false); err != nil {
+		t.Fatal(err)
+	}
+	val, err := runTraced(engine, "(TRACE fact)", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := val.String(); got != "(#<closure FACT>)" {
+		t.Errorf("TRACE should return the traced forms, but got %v", got)
+	}
+	if forms := engine.Traced(); len(forms) != 1 {
+		t.Errorf("one form should be traced, but got %v", forms)
+	}
+}
+
+func TestBreak(t *testing.T) {
+	for _, compiled := range []bool{false, true} {
+		engine := newTraceTestEngine()
+		if _, err := runTraced(engine, srcFact, compiled); err != nil {
+			t.Fatal(err)
+		}
+		var args []string
+		engine.SetBreakHandler(func(ev *sxpf.TraceEvent) error {
+			val, err := sxpf.Evaluate(ev.Env, engine.MakeSymbol("x"))
+			if err != nil {
+				return err
+			}
+			args = append(args, val.String())
+			return nil
+		})
+		if _, err := runTraced(engine, "(LET ((x 7)) (BREAK) (LET ((x 8)) (BREAK)))", compiled); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(args, " "); got != "7 8" {
+			t.Errorf("%v: BREAK should see 7 8, but got %v", compiled, got)
+		}
+
+		args = nil
+		errStop := errors.New("stop")
+		engine.SetBreakHandler(func(ev *sxpf.TraceEvent) error {
+			args = append(args, ev.Args[0].String())
+			if len(args) == 2 {
+				return errStop
+			}
+			return nil
+		})
+		fact, err := engine.LookupForm(engine.MakeSymbol("fact"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.Break(fact)
+		if _, err = runTraced(engine, "(fact 3)", compiled); !errors.Is(err, errStop) {
+			t.Errorf("%v: break handler should stop evaluation, but got %v", compiled, err)
+		}
+		if got := strings.Join(args, " "); got != "3 2" {
+			t.Errorf("%v: breakpoint should see 3 2, but got %v", compiled, got)
+		}
+		engine.Unbreak()
+		if val, err := runTraced(engine, "(fact 3)", compiled); err != nil || val.String() != "6" {
+			t.Errorf("%v: without breakpoints, 6 expected, but got %v / %v", compiled, val, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// symbolFinder is implemented by symbol makers that support transient
+// symbols.
+type symbolFinder interface {
+	// findSymbol returns the symbol with the given name, if it was already
+	// created. Otherwise, it returns a new transient symbol, that is not
+	// stored.
+	findSymbol(string) (*Symbol, bool)
+}
+
+// symbolHome stores how a transient symbol is promoted.
+type symbolHome struct {
+	smk      SymbolMaker
+	name     string
+	promoted atomic.Value // the stored symbol, after promotion
+}
+
+// stored returns the symbol that stores the bindings of a promoted symbol,
+// or nil if the symbol is still transient.
+func (home *symbolHome) stored() *Symbol {
+	sym, _ := home.promoted.Load().(*Symbol)
+	return sym
+}
+
+// TransientSymbols is a SymbolMaker for parsing untrusted input, e.g. in a
+// long-running server. Typically, a new one is used for every parse.
+//
+// If the base symbol maker already created a symbol with a given name, this
+// symbol is returned. Otherwise, a transient symbol is created, which is not
+// stored in the base symbol maker. It is reclaimed by the garbage collector,
+// when it is no longer referenced. A transient symbol is promoted, i.e.
+// stored in the base symbol maker, when it is defined in a scope that is not
+// local to a closure call or a LET form, when a property is set, or when
+// Symbol.Promote is called. If the base symbol
+// maker does not support transient symbols, e.g. because it is not based on
+// a SymbolTable or on Namespaces, all symbols are created by it.
+//
+// The number of symbols created for new names can be limited. If the limit
+// is reached, MakeSymbol returns nil and a parser returns ErrTooManySymbols.
+//
+// A TransientSymbols must not be used concurrently. Its symbols, however,
+// may be used and promoted concurrently.
+type TransientSymbols struct {
+	base   SymbolMaker
+	limit  int
+	count  int
+	syms   map[transientKey]*Symbol
+	folded map[transientKey]*Symbol // symbols with other spellings share the bindings
+}
+
+type transientKey struct {
+	ns   string
+	name string
+}
+
+// NewTransientSymbols creates a new symbol maker, that creates transient
+// symbols for names unknown to the base symbol maker. At most limit symbols
+// are created for new names; a limit less than one means no limit.
+func NewTransientSymbols(base SymbolMaker, limit int) *TransientSymbols {
+	return &TransientSymbols{
+		base:   base,
+		limit:  limit,
+		syms:   map[transientKey]*Symbol{},
+		folded: map[transientKey]*Symbol{},
+	}
+}
+
+// Count returns the number of symbols created for new names.
+func (ts *TransientSymbols) Count() int { return ts.count }
+
+// MakeSymbol returns the symbol of the base symbol maker, if it exists.
+// Otherwise a transient symbol is returned.
+func (ts *TransientSymbols) MakeSymbol(s string) *Symbol {
+	finder, ok := ts.base.(symbolFinder)
+	if !ok {
+		key := transientKey{name: s}
+		if sym, found := ts.syms[key]; found {
+			return sym
+		}
+		if ts.limitReached() {
+			return nil
+		}
+		ts.count++
+		sym := ts.base.MakeSymbol(s)
+		ts.syms[key] = sym
+		return sym
+	}
+
+	sym, found := finder.findSymbol(s)
+	if found || sym == nil {
+		return sym
+	}
+	key := transientKey{name: sym.val}
+	if sym.ns != nil {
+		key.ns = sym.ns.name
+	}
+	if known, exists := ts.syms[key]; exists {
+		return known
+	}
+	if ts.limitReached() {
+		return nil
+	}
+	ts.count++
+	ts.syms[key] = sym
+	if !sym.sensitive {
+		foldedKey := transientKey{ns: strings.ToUpper(key.ns), name: strings.ToUpper(key.name)}
+		if canonical, exists := ts.folded[foldedKey]; exists {
+			sym.canonical, sym.home = canonical, nil
+			return sym
+		}
+		ts.folded[foldedKey] = sym
+	}
+	return sym
+}
+
+func (ts *TransientSymbols) limitReached() bool {
+	return ts.limit > 0 && ts.count >= ts.limit
+}
+
+// formerKey returns the transient symbol, that was used to bind a value to
+// the symbol before it was promoted, or nil.
+func (sym *Symbol) formerKey() *Symbol {
+	for sym.canonical != nil {
+		sym = sym.canonical
+	}
+	if sym.home != nil && sym.home.stored() != nil {
+		return sym
+	}
+	return nil
+}
+
+// IsTransient returns true, if the symbol is transient and was not promoted.
+func (sym *Symbol) IsTransient() bool { return sym != nil && sym.key().home != nil }
+
+// Promote stores a transient symbol in the symbol maker it was created for.
+// Afterwards, it shares its bindings and properties with the stored symbol.
+// Values bound to it before remain bound. Other symbols are not changed.
+// Promote may be called concurrently.
+func (sym *Symbol) Promote() {
+	if sym == nil {
+		return
+	}
+	if root := sym.key(); root.home != nil {
+		root.home.promoted.Store(root.home.smk.MakeSymbol(root.home.name).key())
+	}
+}
@@ -0,0 +1,234 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/t73fde/sxpf"
+	"github.com/t73fde/sxpf/builtins"
+)
+
+func TestTransientSymbols(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	known := smk.MakeSymbol("known")
+	ts := sxpf.NewTransientSymbols(smk, 0)
+	if sym := ts.MakeSymbol("KNOWN"); sym != known {
+		t.Error("existing symbol must be returned")
+	}
+	sym := ts.MakeSymbol("new")
+	if !sym.IsTransient() || sym != ts.MakeSymbol("NEW") || ts.Count() != 1 {
+		t.Error("new name must result in one transient symbol")
+	}
+	if !sym.Equal(smk.MakeSymbol("new")) {
+		t.Error("transient symbol must be equal to symbol with the same name")
+	}
+
+	other := sxpf.NewTransientSymbols(smk, 0).MakeSymbol("other")
+	sxpf.NewSymbolMap(nil).Set(other, sxpf.Nil())
+	if !other.IsTransient() {
+		t.Error("symbol bound in a symbol map must not be promoted")
+	}
+	sxpf.NewEngine(smk).Define(other, sxpf.Nil())
+	if other.IsTransient() {
+		t.Error("defined symbol must be promoted")
+	}
+	if sxpf.NewTransientSymbols(smk, 0).MakeSymbol("other").IsTransient() {
+		t.Error("promoted symbol must be known to the base symbol maker")
+	}
+}
+
+func TestTransientSymbolsEval(t *testing.T) {
+	smk := sxpf.NewSymbolMaker(sxpf.CasePreserving)
+	engine := sxpf.NewEngine(smk)
+	srcs := []string{"(DEFINE foo 17)", "(BEGIN (DEFINE Bar 4) bar)", "Foo", "BAR"}
+	exps := []string{"foo", "4", "17", "4"}
+	for i, src := range srcs {
+		expr, err := sxpf.ParseString(sxpf.NewTransientSymbols(smk, 10), src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := engine.Eval(expr)
+		if err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, src, err)
+			continue
+		}
+		if got := val.String(); got != exps[i] {
+			t.Errorf("%d: %v should evaluate to %v, but got: %v", i, src, exps[i], got)
+		}
+	}
+
+	expr, err := sxpf.ParseString(sxpf.NewTransientSymbols(smk, 0), "(unknown)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = engine.Eval(expr); err == nil {
+		t.Error("unknown symbol must not be bound")
+	}
+	if !sxpf.NewTransientSymbols(smk, 0).MakeSymbol("unknown").IsTransient() {
+		t.Error("unbound symbol must not be promoted")
+	}
+}
+
+func TestTransientSymbolsLocal(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	engine := sxpf.NewEngine(smk)
+	srcs := []string{
+		"(LET ((a 1)) a)",
+		"((LAMBDA (b) b) 2)",
+		"(HANDLER-CASE (RAISE (QUOTE x) \"m\") (ERROR (c) 3))",
+		"((LAMBDA () (DEFINE d 4) d))",
+	}
+	for i, src := range srcs {
+		ts := sxpf.NewTransientSymbols(smk, 0)
+		expr, err := sxpf.ParseString(ts, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = engine.Eval(expr); err != nil {
+			t.Errorf("%d: %v resulted in error: %v", i, src, err)
+		}
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if !sxpf.NewTransientSymbols(smk, 0).MakeSymbol(name).IsTransient() {
+			t.Errorf("local symbol %v must not be promoted", name)
+		}
+	}
+}
+
+func TestTransientSymbolsPromoteLocal(t *testing.T) {
+	testcases := []struct {
+		src string
+		exp string
+	}{
+		{"(LET ((yy 1)) (PUT (QUOTE yy) (QUOTE k) 1) yy)", "1"},
+		{"((LAMBDA (zz) (BEGIN (PUT (QUOTE zz) (QUOTE k) 1) zz)) 5)", "5"},
+		{"(LET ((ww 1)) (PUT (QUOTE ww) (QUOTE k) 1) (SET! ww 2) ww)", "2"},
+	}
+	for i, tc := range testcases {
+		for _, compiled := range []bool{false, true} {
+			smk := sxpf.NewTrivialSymbolMaker()
+			engine := sxpf.NewEngine(smk)
+			builtins.Register(engine)
+			expr, err := sxpf.ParseString(sxpf.NewTransientSymbols(smk, 0), tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var val sxpf.Value
+			if compiled {
+				var code *sxpf.Code
+				if code, err = engine.Compile(expr); err == nil {
+					val, err = code.Run()
+				}
+			} else {
+				val, err = engine.Eval(expr)
+			}
+			if err != nil {
+				t.Errorf("%d/%v: %v resulted in error: %v", i, compiled, tc.src, err)
+				continue
+			}
+			if got := val.String(); got != tc.exp {
+				t.Errorf("%d/%v: %v should evaluate to %v, but got: %v", i, compiled, tc.src, tc.exp, got)
+			}
+		}
+	}
+}
+
+func TestTransientSymbolsPromoteConcurrent(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	sym := sxpf.NewTransientSymbols(smk, 0).MakeSymbol("shared")
+	sm := sxpf.NewSymbolMap(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sm.Lookup(sym)
+			sym.Promote()
+			sm.Lookup(sym)
+		}()
+	}
+	wg.Wait()
+	if sym.IsTransient() || !sym.Equal(smk.MakeSymbol("shared")) {
+		t.Error("symbol must be promoted")
+	}
+}
+
+func TestTransientSymbolsNamespaces(t *testing.T) {
+	nss := sxpf.NewNamespaces(sxpf.CaseFolding)
+	engine := sxpf.NewEngine(nss)
+	expr, err := sxpf.ParseString(sxpf.NewTransientSymbols(nss, 0), "(BEGIN (DEFINE lib/x 1) (QUOTE lib/x))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := engine.Eval(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := val.String(); got != "LIB/X" {
+		t.Errorf("expected LIB/X, but got %v", got)
+	}
+	if val, found := engine.SymbolMap().Lookup(nss.MakeSymbol("lib/x")); !found || val.String() != "1" {
+		t.Errorf("LIB/X should be bound to 1, but got %v/%v", val, found)
+	}
+
+	sym1 := sxpf.NewTransientSymbols(nss, 0).MakeSymbol("other/y")
+	sym2 := sxpf.NewTransientSymbols(nss, 0).MakeSymbol("OTHER/Y")
+	if !sym1.IsTransient() || !sym1.Equal(sym2) || sym1.String() != "OTHER/Y" {
+		t.Errorf("symbols of an unknown namespace should be equal transient symbols, but got %v/%v", sym1, sym2)
+	}
+}
+
+func TestTransientSymbolsLimit(t *testing.T) {
+	smk := sxpf.NewTrivialSymbolMaker()
+	smk.MakeSymbol("known")
+	testcases := []struct {
+		src   string
+		limit int
+		err   error
+	}{
+		{"(a b c)", 3, nil},
+		{"(a b c d)", 3, sxpf.ErrTooManySymbols},
+		{"(a a a known known)", 1, nil},
+		{"(a b)", 0, nil},
+	}
+	for i, tc := range testcases {
+		_, err := sxpf.ParseString(sxpf.NewTransientSymbols(smk, tc.limit), tc.src)
+		if err != tc.err {
+			t.Errorf("%d: parsing %q with limit %d should result in %v, but got %v", i, tc.src, tc.limit, tc.err, err)
+		}
+	}
+	if !sxpf.NewTransientSymbols(smk, 0).MakeSymbol("a").IsTransient() {
+		t.Error("parsing must not create symbols in the base symbol maker")
+	}
+}
+
+func TestTransientSymbolsSpecialClauses(t *testing.T) {
+	ts := sxpf.NewTransientSymbols(sxpf.NewTrivialSymbolMaker(), 0)
+	engine := sxpf.NewEngine(ts)
+	for _, src := range []string{
+		"(COND (() 1) (#t 2))",
+		"(HANDLER-CASE (RAISE (QUOTE x) \"m\") (x (c) 1))",
+	} {
+		expr, err := sxpf.ParseString(ts, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := ts.Count()
+		if _, err = engine.Eval(expr); err != nil {
+			t.Errorf("%v resulted in error: %v", src, err)
+		}
+		if got := ts.Count(); got != count {
+			t.Errorf("evaluating %v should not create symbols, but %d were created", src, got-count)
+		}
+	}
+}
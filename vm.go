@@ -0,0 +1,488 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2022 Detlef Stern
+//
+// This file is part of sxpf.
+//
+// sxpf is licensed under the latest version of the EUPL // (European Union
+// Public License). Please see file LICENSE.txt for your rights and obligations
+// under this license.
+//-----------------------------------------------------------------------------
+
+package sxpf
+
+import "fmt"
+
+// opcode is the operation of an instruction.
+type opcode uint8
+
+// Constants for opcode. Operands are named a, b, and c.
+const (
+	opConst       opcode = iota // push constant a
+	opLocal                     // push slot b of the frame at depth a
+	opLocalDef                  // like opLocal and jump to c; continue, if the slot is unbound
+	opGlobal                    // push the value of the symbol constant a, using cell b
+	opCheckBound                // fail, if the symbol constant a is not bound, using cell b
+	opSetLocal                  // set slot b of the frame at depth a to the top value
+	opSetLocalDef               // like opSetLocal and jump to c; continue, if the slot is unbound
+	opSetGlobal                 // set the bound symbol constant a to the top value, using cell b
+	opDefLocal                  // pop a value, bind it to slot a, push the symbol constant b
+	opDefGlobal                 // pop a value, define the symbol constant a, push it
+	opPop                       // pop a value
+	opJump                      // jump to a
+	opJumpFalse                 // pop a value, jump to a if it is false
+	opJumpTrue                  // jump to a, if the top value is true; otherwise pop it
+	opClosure                   // push a closure of prototype a
+	opAlloc                     // allocate a values
+	opEnter                     // enter a new frame of LET a
+	opLeave                     // leave the current frame
+	opHead                      // push the form bound to the head symbol of call a
+	opCheckHead                 // check that the top value is a form for call a
+	opCall                      // call a, push the result
+	opTailCall                  // call a in tail position, return the tail call
+	opNotCall                   // fail, since constant a is not a form call
+	opInterp                    // evaluate constant a by the interpreter, return the tail call
+	opGuard                     // continue, if guard a holds; otherwise evaluate its value and jump to c
+)
+
+var opNames = [...]string{
+	opConst:       "CONST",
+	opLocal:       "LOCAL",
+	opLocalDef:    "LOCAL-DEF",
+	opGlobal:      "GLOBAL",
+	opCheckBound:  "CHECK-BOUND",
+	opSetLocal:    "SET-LOCAL",
+	opSetLocalDef: "SET-LOCAL-DEF",
+	opSetGlobal:   "SET-GLOBAL",
+	opDefLocal:    "DEF-LOCAL",
+	opDefGlobal:   "DEF-GLOBAL",
+	opPop:         "POP",
+	opJump:        "JUMP",
+	opJumpFalse:   "JUMP-FALSE",
+	opJumpTrue:    "JUMP-TRUE",
+	opClosure:     "CLOSURE",
+	opAlloc:       "ALLOC",
+	opEnter:       "ENTER",
+	opLeave:       "LEAVE",
+	opHead:        "HEAD",
+	opCheckHead:   "CHECK-HEAD",
+	opCall:        "CALL",
+	opTailCall:    "TAIL-CALL",
+	opNotCall:     "NOT-CALL",
+	opInterp:      "INTERP",
+	opGuard:       "GUARD",
+}
+
+func (op opcode) String() string { return opNames[op] }
+
+// instr is one instruction of the virtual machine.
+type instr struct {
+	op      opcode
+	a, b, c int32
+}
+
+// block is a sequence of instructions, together with the tables they refer
+// to. The result of a block is the top value of the stack, or the tail call
+// returned by opTailCall or opInterp.
+type block struct {
+	code     []instr
+	consts   []Value
+	calls    []*callSite
+	lets     []*letSite
+	protos   []*proto
+	regions  []region
+	guards   []*guardSite
+	cells    []bindingCell // cached bindings of global symbols
+	maxStack int
+}
+
+// callSite describes a form call.
+type callSite struct {
+	expr  Value   // the expression of the call
+	head  Value   // the head of the call
+	args  []Value // the unevaluated arguments
+	scope *scope  // the lexical scope of the call, nil at top level
+	end   int     // the instruction after the call
+	tail  bool    // call is in tail position
+	cell  bindingCell
+}
+
+// guardSite describes the assumption, that a global symbol is bound to a
+// builtin, under which a value was compiled. If it does not hold, the value
+// is evaluated without the assumption.
+type guardSite struct {
+	sym   *Symbol
+	want  *Builtin
+	expr  Value  // the value, that was compiled
+	scope *scope // the lexical scope of the value, nil at top level
+	tail  bool   // value is in tail position
+	cell  bindingCell
+}
+
+// letSite describes the new frame of a LET form.
+type letSite struct {
+	size  int   // number of slots
+	slots []int // slots of the binding values
+}
+
+// proto is the prototype of a closure created by a LAMBDA expression.
+type proto struct {
+	name   string
+	doc    string
+	params []*Symbol
+	rest   *Symbol
+	size   int // number of slots
+	body   *block
+}
+
+// region is a range of instructions that evaluate the arguments of a call.
+// If one of them fails, the call is recorded in the error.
+type region struct {
+	start, end int
+	expr       Value
+	form       Form    // the form, if it is known at compile time
+	args       []Value // the unevaluated arguments of a special form
+	headPos    int     // stack position of the form, or -1
+}
+
+// vmFrame stores the values of the local variables of a lexical scope.
+// An unbound slot contains nil.
+type vmFrame struct {
+	parent *vmFrame
+	slots  []Value
+}
+
+func (f *vmFrame) up(depth int32) *vmFrame {
+	for ; depth > 0; depth-- {
+		f = f.parent
+	}
+	return f
+}
+
+// run executes the block with the given frame.
+func (blk *block) run(e *Engine, frame *vmFrame) (Value, error) {
+	st := e.state
+	stack := make([]Value, 0, blk.maxStack)
+	code := blk.code
+	pc := 0
+	for pc < len(code) {
+		in := &code[pc]
+		pc++
+		switch in.op {
+		case opConst:
+			stack = append(stack, blk.consts[in.a])
+		case opLocal:
+			stack = append(stack, frame.up(in.a).slots[in.b])
+		case opLocalDef:
+			if val := frame.up(in.a).slots[in.b]; val != nil {
+				stack = append(stack, val)
+				pc = int(in.c)
+			}
+		case opGlobal:
+			sym := blk.consts[in.a].(*Symbol)
+			val, found := e.symMap.lookupCached(&blk.cells[in.b], sym)
+			if !found {
+				return nil, blk.addFrames(e, ErrNotBound(sym), pc-1, stack)
+			}
+			stack = append(stack, val)
+		case opCheckBound:
+			if sm, _ := e.symMap.lookupCell(&blk.cells[in.b], blk.consts[in.a].(*Symbol)); sm == nil {
+				return nil, blk.addFrames(e, ErrNotBound(blk.consts[in.a].(*Symbol)), pc-1, stack)
+			}
+		case opSetLocal:
+			frame.up(in.a).slots[in.b] = stack[len(stack)-1]
+		case opSetLocalDef:
+			if f := frame.up(in.a); f.slots[in.b] != nil {
+				f.slots[in.b] = stack[len(stack)-1]
+				pc = int(in.c)
+			}
+		case opSetGlobal:
+			sym := blk.consts[in.a].(*Symbol)
+			sm, boundSym := e.symMap.lookupCell(&blk.cells[in.b], sym)
+			if sm == nil {
+				return nil, blk.addFrames(e, ErrNotBound(sym), pc-1, stack)
+			}
+			sm.Set(boundSym, stack[len(stack)-1])
+		case opDefLocal:
+			sym := blk.consts[in.b].(*Symbol)
+			frame.slots[in.a] = nameClosure(stack[len(stack)-1], sym)
+			stack[len(stack)-1] = sym
+		case opDefGlobal:
+			sym := blk.consts[in.a].(*Symbol)
+			e.Define(sym, nameClosure(stack[len(stack)-1], sym))
+			stack[len(stack)-1] = sym
+		case opPop:
+			stack = stack[:len(stack)-1]
+		case opJump:
+			pc = int(in.a)
+		case opJumpFalse:
+			test := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !IsTrue(test) {
+				pc = int(in.a)
+			}
+		case opJumpTrue:
+			if IsTrue(stack[len(stack)-1]) {
+				pc = int(in.a)
+			} else {
+				stack = stack[:len(stack)-1]
+			}
+		case opClosure:
+			p := blk.protos[in.a]
+			stack = append(stack, &Closure{
+				name: p.name, params: p.params, rest: p.rest, env: e, doc: p.doc, proto: p, frame: frame})
+		case opAlloc:
+			if err := st.allocate(int(in.a)); err != nil {
+				return nil, blk.addFrames(e, err, pc-1, stack)
+			}
+		case opEnter:
+			ls := blk.lets[in.a]
+			n := len(ls.slots)
+			frame = &vmFrame{parent: frame, slots: make([]Value, ls.size)}
+			for i, slot := range ls.slots {
+				frame.slots[slot] = stack[len(stack)-n+i]
+			}
+			stack = stack[:len(stack)-n]
+		case opLeave:
+			frame = frame.parent
+		case opHead:
+			cs := blk.calls[in.a]
+			sym := cs.head.(*Symbol)
+			val, found := e.symMap.lookupCached(&cs.cell, sym)
+			if m, isMacro := val.(*Macro); isMacro {
+				res, err := cs.expand(e, frame, m)
+				if err != nil {
+					return nil, blk.addFrames(e, err, pc-1, stack)
+				}
+				if cs.tail {
+					return res, nil
+				}
+				stack, pc = append(stack, res), cs.end
+				continue
+			}
+			form, isForm := val.(Form)
+			if !found || !isForm {
+				return nil, blk.addFrames(e, addFrame(e, ErrNotFormBound(sym), cs.expr, nil, nil), pc-1, stack)
+			}
+			if form.IsSpecial() {
+				res, err := cs.callSpecial(e, frame, form)
+				if err != nil {
+					return nil, blk.addFrames(e, err, pc-1, stack)
+				}
+				if cs.tail {
+					return res, nil
+				}
+				stack, pc = append(stack, res), cs.end
+				continue
+			}
+			stack = append(stack, form)
+		case opCheckHead:
+			cs := blk.calls[in.a]
+			val := stack[len(stack)-1]
+			if m, isMacro := val.(*Macro); isMacro {
+				if _, isSymbol := cs.head.(*Symbol); isSymbol {
+					res, err := cs.expand(e, frame, m)
+					if err != nil {
+						return nil, blk.addFrames(e, err, pc-1, stack)
+					}
+					if cs.tail {
+						return res, nil
+					}
+					stack[len(stack)-1], pc = res, cs.end
+					continue
+				}
+			}
+			form, isForm := val.(Form)
+			if !isForm {
+				err := ErrNotForm(cs.head, val)
+				if sym, isSymbol := cs.head.(*Symbol); isSymbol {
+					err = ErrNotFormBound(sym)
+				}
+				return nil, blk.addFrames(e, addFrame(e, err, cs.expr, nil, nil), pc-1, stack)
+			}
+			if form.IsSpecial() {
+				res, err := cs.callSpecial(e, frame, form)
+				if err != nil {
+					return nil, blk.addFrames(e, err, pc-1, stack)
+				}
+				if cs.tail {
+					return res, nil
+				}
+				stack[len(stack)-1], pc = res, cs.end
+			}
+		case opCall, opTailCall:
+			cs := blk.calls[in.a]
+			n := len(cs.args)
+			base := len(stack) - n - 1
+			form := stack[base].(Form)
+			args := make([]Value, n)
+			copy(args, stack[base+1:])
+			stack = stack[:base]
+			if err := st.step(); err != nil {
+				return nil, blk.addFrames(e, err, pc-1, stack)
+			}
+			if st.depth >= st.maxDepth {
+				return nil, blk.addFrames(e, &MaxDepthError{st.maxDepth}, pc-1, stack)
+			}
+			if err := st.allocate(n + 1); err != nil {
+				return nil, blk.addFrames(e, err, pc-1, stack)
+			}
+			if in.op == opTailCall {
+				return &tailCall{env: e, val: cs.expr, form: form, args: args}, nil
+			}
+			st.depth++
+			res, err := resolveTailCall(callForm(e, cs.expr, form, args))
+			st.depth--
+			if err != nil {
+				return nil, blk.addFrames(e, addFrame(e, err, cs.expr, form, args), pc-1, stack)
+			}
+			stack = append(stack, res)
+		case opNotCall:
+			return nil, blk.addFrames(e, fmt.Errorf("%v is not a form call", blk.consts[in.a]), pc-1, stack)
+		case opInterp:
+			return TailCall(e, blk.consts[in.a]), nil
+		case opGuard:
+			gs := blk.guards[in.a]
+			if val, _ := e.symMap.lookupCached(&gs.cell, gs.sym); val == Value(gs.want) {
+				continue
+			}
+			if err := st.step(); err != nil {
+				return nil, blk.addFrames(e, err, pc-1, stack)
+			}
+			if gs.scope != nil {
+				// The cached block of the value might contain this guard.
+				delete(gs.scope.cache, gs.expr)
+			}
+			env := scopeEnv(e, gs.scope, frame)
+			if gs.tail {
+				return TailCall(env, gs.expr), nil
+			}
+			res, err := Evaluate(env, gs.expr)
+			if err != nil {
+				return nil, blk.addFrames(e, err, pc-1, stack)
+			}
+			stack, pc = append(stack, res), int(in.c)
+		default:
+			panic(fmt.Sprintf("unknown opcode %v", in.op))
+		}
+	}
+	return stack[len(stack)-1], nil
+}
+
+// nameClosure gives an anonymous closure the name of the symbol it is bound
+// to.
+func nameClosure(val Value, sym *Symbol) Value {
+	if c, isClosure := val.(*Closure); isClosure && c.name == "" {
+		c.name = sym.GetValue()
+	}
+	return val
+}
+
+// addFrames records the calls, whose arguments were evaluated when the
+// instruction at pc failed.
+func (blk *block) addFrames(e *Engine, err error, pc int, stack []Value) error {
+	for _, r := range blk.regions {
+		if r.start <= pc && pc < r.end {
+			form := r.form
+			if r.headPos >= 0 {
+				form, _ = stack[r.headPos].(Form)
+			}
+			err = addFrame(e, err, r.expr, form, r.args)
+		}
+	}
+	return err
+}
+
+// env returns the environment that is used to call a special form.
+func (cs *callSite) env(e *Engine, frame *vmFrame) Environment {
+	return scopeEnv(e, cs.scope, frame)
+}
+
+// scopeEnv returns the environment to evaluate a value in the given lexical
+// scope, whose variables are stored in the frame.
+func scopeEnv(e *Engine, sc *scope, frame *vmFrame) Environment {
+	if sc == nil {
+		return e
+	}
+	return &vmEnv{e: e, scope: sc, frame: frame}
+}
+
+// callSpecial calls a special form with the unevaluated arguments.
+func (cs *callSite) callSpecial(e *Engine, frame *vmFrame, form Form) (Value, error) {
+	st := e.state
+	if err := st.step(); err != nil {
+		return nil, err
+	}
+	if st.depth >= st.maxDepth {
+		return nil, &MaxDepthError{st.maxDepth}
+	}
+	env := cs.env(e, frame)
+	st.depth++
+	defer func() { st.depth-- }()
+	res, err := callForm(env, cs.expr, form, cs.args)
+	if err == nil && !cs.tail {
+		res, err = resolveTailCall(res, err)
+	}
+	if err != nil {
+		return nil, addFrame(e, err, cs.expr, form, cs.args)
+	}
+	return res, nil
+}
+
+// expand expands a call of a macro, that was defined after the call was
+// compiled, and evaluates the expansion.
+func (cs *callSite) expand(e *Engine, frame *vmFrame, m *Macro) (Value, error) {
+	exp, err := m.Expand(e, cs.args)
+	if err != nil {
+		return nil, err
+	}
+	env := cs.env(e, frame)
+	if cs.tail {
+		return TailCall(env, exp), nil
+	}
+	return Evaluate(env, exp)
+}
+
+// vmEnv is the environment of a special form, that is called by compiled
+// code within a lexical scope. Values are compiled in this scope and
+// executed with the frame of the call.
+type vmEnv struct {
+	e     *Engine
+	scope *scope
+	frame *vmFrame
+}
+
+func (ve *vmEnv) LookupForm(sym *Symbol) (Form, error) {
+	if _, _, _, found := ve.scope.resolve(sym); !found {
+		return ve.e.LookupForm(sym)
+	}
+	val, err := ve.eval(sym)
+	if err != nil {
+		return nil, err
+	}
+	if form, ok := val.(Form); ok {
+		return form, nil
+	}
+	return nil, ErrNotFormBound(sym)
+}
+
+func (ve *vmEnv) EvaluateString(str *String) (Value, error) { return ve.eval(str) }
+func (ve *vmEnv) EvaluateSymbol(sym *Symbol) (Value, error) { return ve.eval(sym) }
+func (ve *vmEnv) EvaluateList(p *Pair) (Value, error)       { return ve.eval(p) }
+func (ve *vmEnv) EvaluateVector(v *Vector) (Value, error)   { return ve.eval(v) }
+
+func (ve *vmEnv) eval(val Value) (Value, error) {
+	blk, err := ve.scope.compiled(ve.e, val)
+	if err != nil {
+		return nil, err
+	}
+	return blk.run(ve.e, ve.frame)
+}
+
+// MakeSymbol creates a symbol by using the symbol maker of the engine.
+func (ve *vmEnv) MakeSymbol(s string) *Symbol { return ve.e.MakeSymbol(s) }
+
+// Allocate announces the allocation of approximately n values.
+func (ve *vmEnv) Allocate(n int) error { return ve.e.Allocate(n) }
+
+// Position returns the source position of the given value, if it is known.
+func (ve *vmEnv) Position(val Value) (Position, bool) { return ve.e.Position(val) }